@@ -0,0 +1,160 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// accessTracker records the labels read from a Config.
+type accessTracker struct {
+	reads   sync.Map    // Set of valueKey read.
+	enabled atomic.Bool // Reads are being recorded.
+}
+
+// record records a read of label l of section s.
+func (at *accessTracker) record(s string, l string) {
+	if !at.enabled.Load() {
+		return
+	}
+
+	key := valueKey{s, l}
+	if _, exists := at.reads.Load(key); !exists {
+		at.reads.Store(key, true)
+	}
+}
+
+// read tests if label l of section s was read.
+func (at *accessTracker) read(s string, l string) bool {
+	_, exists := at.reads.Load(valueKey{s, l})
+	return exists
+}
+
+// track records a read of label l of section s if tracking is enabled.
+func (snap *Snapshot) track(s string, l string) {
+	if snap.tracker != nil {
+		snap.tracker.record(s, l)
+	}
+}
+
+// SetAccessTracking enables or disables recording the labels read with
+// Value and the typed getters, from c or from its snapshots. Enabling
+// tracking discards the reads recorded before. Disabling it keeps them for
+// AccessReport.
+func (c *Config) SetAccessTracking(enable bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.tracker != nil {
+		c.tracker.enabled.Store(false)
+	}
+
+	if enable {
+		c.tracker = new(accessTracker)
+		c.tracker.enabled.Store(true)
+	}
+
+	c.republish()
+}
+
+// activeTracker returns the tracker of new snapshots, nil if tracking is
+// disabled. Must be called with the writer lock held.
+func (c *Config) activeTracker() *accessTracker {
+	if c.tracker == nil || !c.tracker.enabled.Load() {
+		return nil
+	}
+
+	return c.tracker
+}
+
+// Access identifies a label in an AccessReport.
+type Access struct {
+	Section     string   // Section name.
+	Label       string   // Label.
+	Source      string   // Source of the value, empty if undefined.
+	Suggestions []string // Similar labels of the section, for undefined labels.
+}
+
+// AccessReport lists the differences between the labels defined in a
+// Config and the labels read from it, sorted by section and label.
+type AccessReport struct {
+	UnreadSections []string // Sections defined of which no label was read.
+	Unread         []Access // Labels defined but never read.
+	Undefined      []Access // Labels read but never defined.
+}
+
+// AccessReport compares the current contents of c with the reads recorded
+// since access tracking was enabled. It returns nil if tracking was never
+// enabled.
+func (c *Config) AccessReport() *AccessReport {
+	c.lock.Lock()
+	tracker := c.tracker
+	c.lock.Unlock()
+
+	if tracker == nil {
+		return nil
+	}
+
+	snap := c.Snapshot()
+	report := new(AccessReport)
+
+	sections := snap.Sections()
+	sort.Strings(sections)
+
+	for _, s := range sections {
+		labels := snap.Labels(s)
+		sort.Strings(labels)
+
+		unread := 0
+		for _, l := range labels {
+			if !tracker.read(s, l) {
+				report.Unread = append(report.Unread, Access{Section: s, Label: l, Source: snap.Source(s, l)})
+				unread++
+			}
+		}
+
+		if unread == len(labels) {
+			report.UnreadSections = append(report.UnreadSections, s)
+		}
+	}
+
+	tracker.reads.Range(func(key any, _ any) bool {
+		k := key.(valueKey)
+		_, exists := snap.lookup(k.section, k.label)
+		if !exists {
+			report.Undefined = append(report.Undefined, Access{Section: k.section, Label: k.label,
+				Suggestions: suggest(k.label, snap.Labels(k.section))})
+		}
+
+		return true
+	})
+
+	sort.Slice(report.Undefined, func(i, j int) bool {
+		a, b := report.Undefined[i], report.Undefined[j]
+		if a.Section != b.Section {
+			return a.Section < b.Section
+		}
+
+		return a.Label < b.Label
+	})
+
+	return report
+}
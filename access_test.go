@@ -0,0 +1,121 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAccessReport(t *testing.T) {
+	c := NewConfig()
+	if c.AccessReport() != nil {
+		t.Errorf("expected no report")
+	}
+
+	// Reads before tracking are not recorded.
+	c.SetValue("General", "Vehicle", "lauv-xplore-1")
+	c.Value("General", "Vehicle")
+
+	c.SetAccessTracking(true)
+
+	err := NewParser(c).Parse(strings.NewReader(`[General]
+Max Sped = 2
+Depth    = 10
+
+[Navigation]
+Enabled  = true
+
+[Legacy]
+Unused   = 1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	snap := c.Snapshot()
+	snap.Float("General", "Depth")
+	c.Value("General", "Max Speed")
+	c.Bool("Navigation", "Enabled")
+	c.Int("Navigation", "Retries")
+
+	expected := &AccessReport{
+		UnreadSections: []string{"Legacy"},
+		Unread: []Access{
+			{Section: "General", Label: "Max Sped", Source: "2"},
+			{Section: "General", Label: "Vehicle"},
+			{Section: "Legacy", Label: "Unused", Source: "9"},
+		},
+		Undefined: []Access{
+			{Section: "General", Label: "Max Speed", Suggestions: []string{"Max Sped"}},
+			{Section: "Navigation", Label: "Retries"},
+		},
+	}
+
+	actual := c.AccessReport()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %+v, actual: %+v", expected, actual)
+	}
+
+	// Reads after tracking is disabled are not recorded.
+	c.SetAccessTracking(false)
+	c.Value("Legacy", "Unused")
+	c.SetValue("Legacy", "Other", "2")
+	c.Value("Legacy", "Other")
+	snap.Value("General", "Vehicle")
+
+	actual = c.AccessReport()
+	if len(actual.Unread) != 4 || len(actual.UnreadSections) != 1 {
+		t.Errorf("unexpected report: %+v", actual)
+	}
+
+	// Enabling tracking again discards the reads recorded.
+	c.SetAccessTracking(true)
+	actual = c.AccessReport()
+	if len(actual.Unread) != 6 || len(actual.Undefined) != 0 {
+		t.Errorf("unexpected report: %+v", actual)
+	}
+}
+
+func TestAccessTrackingConcurrent(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("S0", "L0", "V0")
+	c.SetAccessTracking(true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Value("S0", "L0")
+				c.SetValue("S0", "L1", "V1")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	report := c.AccessReport()
+	if len(report.Unread) != 1 || report.Unread[0].Label != "L1" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,150 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import "strings"
+
+// condBlock is a conditional block being parsed: If, optional ElseIf and
+// Else directives, and EndIf.
+type condBlock struct {
+	path     string // File of the If directive.
+	lineNr   uint   // Line number of the If directive.
+	active   bool   // Lines of the current branch are parsed.
+	taken    bool   // A branch was taken, or the block is skipped.
+	elseSeen bool   // The Else directive was found.
+}
+
+// isCondDirective tests if name is the name of a directive of conditional
+// blocks.
+func isCondDirective(name string) bool {
+	switch name {
+	case "If", "ElseIf", "Else", "EndIf":
+		return true
+	}
+
+	return false
+}
+
+// skipping tests if the current line is in a branch not taken.
+func (p *Parser) skipping() bool {
+	n := len(p.conds)
+	return n > 0 && !p.conds[n-1].active
+}
+
+// evalCondition evaluates the condition of an If or ElseIf directive:
+// "Name == Value", "Name != Value", or "Name", which tests if variable Name
+// is defined.
+func (p *Parser) evalCondition(cond string) (bool, error) {
+	name, value, op := cond, "", ""
+	for _, candidate := range []string{"==", "!="} {
+		i := strings.Index(cond, candidate)
+		if i >= 0 {
+			name, value, op = cond[:i], cond[i+len(candidate):], candidate
+			break
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if name == "" {
+		return false, &SyntaxError{p.curFile(), p.curLineNr(), "invalid condition: " + cond}
+	}
+
+	varValue, defined := p.Variable(name)
+	if op == "" {
+		return defined, nil
+	}
+
+	if !defined {
+		return false, &SyntaxError{p.curFile(), p.curLineNr(), "undefined variable: " + name}
+	}
+
+	return (varValue == value) == (op == "=="), nil
+}
+
+// handleCond handles the directives of conditional blocks.
+func (p *Parser) handleCond(name string, args string) error {
+	n := len(p.conds)
+	base := p.condBases[len(p.condBases)-1]
+	if name != "If" && n == base {
+		return &SyntaxError{p.curFile(), p.curLineNr(), name + " without If"}
+	}
+
+	switch name {
+	case "If":
+		block := condBlock{path: p.curFile(), lineNr: p.curLineNr()}
+		if p.skipping() {
+			block.taken = true
+		} else {
+			active, err := p.evalCondition(args)
+			if err != nil {
+				return err
+			}
+
+			block.active = active
+			block.taken = active
+		}
+
+		p.conds = append(p.conds, block)
+
+	case "ElseIf":
+		block := &p.conds[n-1]
+		if block.elseSeen {
+			return &SyntaxError{p.curFile(), p.curLineNr(), "ElseIf after Else"}
+		}
+
+		block.active = false
+		if !block.taken {
+			active, err := p.evalCondition(args)
+			if err != nil {
+				return err
+			}
+
+			block.active = active
+			block.taken = active
+		}
+
+	case "Else":
+		block := &p.conds[n-1]
+		if block.elseSeen {
+			return &SyntaxError{p.curFile(), p.curLineNr(), "duplicate Else"}
+		}
+
+		block.elseSeen = true
+		block.active = !block.taken
+		block.taken = true
+
+	case "EndIf":
+		p.conds = p.conds[:n-1]
+	}
+
+	return nil
+}
+
+// checkConds reports the first conditional block of the current file that
+// was not closed.
+func (p *Parser) checkConds() error {
+	base := p.condBases[len(p.condBases)-1]
+	if len(p.conds) > base {
+		block := p.conds[base]
+		return &SyntaxError{block.path, block.lineNr, "If without EndIf"}
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const condInput = `[General]
+Vehicle = lauv-xplore-1
+
+[If Profile == Simulation]
+[Simulator]
+Enabled = true
+[General]
+Speed = 1.0
+[ElseIf Profile == Hardware]
+[General]
+Speed = 1.5
+  [If Sonar]
+  [Sonar]
+  Enabled = true
+  [Else]
+  [Sonar]
+  Enabled = false
+  [EndIf]
+[Else]
+[General]
+Speed = 0
+[EndIf]
+
+[General]
+Depth = 2
+`
+
+func TestConditionals(t *testing.T) {
+	tests := []struct {
+		vars     map[string]string
+		expected map[string]map[string]string
+	}{
+		{
+			map[string]string{"Profile": "Simulation"},
+			map[string]map[string]string{
+				"General":   {"Vehicle": "lauv-xplore-1", "Speed": "1.0", "Depth": "2"},
+				"Simulator": {"Enabled": "true"},
+			},
+		},
+		{
+			map[string]string{"Profile": "Hardware", "Sonar": ""},
+			map[string]map[string]string{
+				"General": {"Vehicle": "lauv-xplore-1", "Speed": "1.5", "Depth": "2"},
+				"Sonar":   {"Enabled": "true"},
+			},
+		},
+		{
+			map[string]string{"Profile": "Hardware"},
+			map[string]map[string]string{
+				"General": {"Vehicle": "lauv-xplore-1", "Speed": "1.5", "Depth": "2"},
+				"Sonar":   {"Enabled": "false"},
+			},
+		},
+		{
+			map[string]string{"Profile": "Bench"},
+			map[string]map[string]string{
+				"General": {"Vehicle": "lauv-xplore-1", "Speed": "0", "Depth": "2"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		p := NewParser(nil)
+		p.Variables = test.vars
+		err := p.Parse(strings.NewReader(condInput))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		actual := p.Config.Map()
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%v: expected: %v, actual: %v", test.vars, test.expected, actual)
+		}
+	}
+}
+
+func TestConditionalsSkipDirectives(t *testing.T) {
+	p := NewParser(nil)
+	p.Variables = map[string]string{"Profile": "Hardware"}
+	err := p.Parse(strings.NewReader(`[S0]
+[If Profile != Hardware]
+[Require missing.ini]
+[If Undefined == 1]
+[EndIf]
+L0 = V0
+[EndIf]
+L1 = V1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"S0": {"L1": "V1"}}
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[S0]\n[If Profile == A]\nL0 = V0\n", "2: If without EndIf"},
+		{"[If Profile == A]\n[If Profile == B]\n[EndIf]\n", "1: If without EndIf"},
+		{"[If Profile == B]\n[EndIf]\n[If Profile == A]\n[If Profile == B]\n[EndIf]\n", "3: If without EndIf"},
+		{"[S0]\n[EndIf]\n", "2: EndIf without If"},
+		{"[Else]\n", "1: Else without If"},
+		{"[ElseIf Profile == A]\n", "1: ElseIf without If"},
+		{"[If Profile == A]\n[Else]\n[Else]\n[EndIf]\n", "3: duplicate Else"},
+		{"[If Profile == A]\n[Else]\n[ElseIf Profile == B]\n[EndIf]\n", "3: ElseIf after Else"},
+		{"[If Mode == A]\n[EndIf]\n", "1: undefined variable: Mode"},
+		{"[If == A]\n[EndIf]\n", "1: invalid condition: == A"},
+	}
+
+	for _, test := range tests {
+		p := NewParser(nil)
+		p.Variables = map[string]string{"Profile": "A"}
+		err := p.Parse(strings.NewReader(test.input))
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+	}
+}
+
+func TestConditionalsPerFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.ini":   "[If Profile == A]\n[Require open.ini]\n[EndIf]\n",
+		"open.ini":   "[S0]\nL0 = V0\n[If Profile == A]\n",
+		"closed.ini": "[EndIf]\n",
+		"other.ini":  "[If Profile == A]\n[Require closed.ini]\n",
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"main.ini", filepath.Join(dir, "open.ini") + ":3: If without EndIf"},
+		{"other.ini", filepath.Join(dir, "closed.ini") + ":1: EndIf without If"},
+	}
+
+	for _, test := range tests {
+		p := NewParser(nil)
+		p.Variables = map[string]string{"Profile": "A"}
+		err := p.ParseFile(filepath.Join(dir, test.file))
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+	}
+}
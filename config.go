@@ -18,20 +18,30 @@
 
 package ini
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // Config organizes configuration values in sections. Each section may
 // contain an arbitrary number of unique labels with associated values.
-// Instances of Config are thread-safe.
+// Instances of Config are thread-safe. Reads never block: each write
+// publishes a new immutable Snapshot, which readers load atomically.
 type Config struct {
-	cfg  map[string]map[string]string
-	lock sync.RWMutex
+	snap        atomic.Pointer[Snapshot] // Current contents.
+	lock        sync.Mutex               // Serializes writers.
+	subs        map[*Subscription]bool   // Subscribers.
+	subsLock    sync.Mutex               // Guards subs.
+	history     []*Revision              // Committed revisions, oldest first.
+	historySize int                      // Maximum number of revisions kept.
+	tracker     *accessTracker           // Recorder of reads.
+	exprs       bool                     // Numeric getters evaluate expressions.
 }
 
 // NewConfig creates a new instance of Config.
 func NewConfig() *Config {
 	c := new(Config)
-	c.cfg = make(map[string]map[string]string)
+	c.snap.Store(emptySnapshot)
 	return c
 }
 
@@ -54,95 +64,168 @@ func cloneTable(t map[string]map[string]string) map[string]map[string]string {
 	return newT
 }
 
-func (c *Config) setValueNoLock(section string, label string, value string) {
-	_, exists := c.cfg[section]
-	if !exists {
-		c.cfg[section] = make(map[string]string)
+// Snapshot returns the current contents of the configuration. The
+// snapshot is not affected by later modifications of c.
+func (c *Config) Snapshot() *Snapshot {
+	snap := c.snap.Load()
+	if snap == nil {
+		return emptySnapshot
 	}
 
-	c.cfg[section][label] = value
+	return snap
 }
 
-// Map returns a copy of the configuration contents.
-func (c *Config) Map() map[string]map[string]string {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
-
-	return cloneTable(c.cfg)
+// begin starts a transaction on the current contents. Unlike Update, the
+// transaction does not block other writers and is published with commit.
+func (c *Config) begin() *Tx {
+	return newTx(c.Snapshot())
 }
 
-// SetMap replaces the current configuration with a copy of map m.
-func (c *Config) SetMap(m map[string]map[string]string) {
+// commit atomically publishes the modifications of transaction tx. If c was
+// modified since tx started, the operations of tx are applied again on the
+// current contents.
+func (c *Config) commit(tx *Tx) {
+	if len(tx.ops) == 0 {
+		return
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	c.cfg = cloneTable(m)
+	cur := c.Snapshot()
+	if cur != tx.base {
+		tx = tx.rebase(cur)
+	}
+
+	c.publish(tx)
 }
 
-// Sections returns an unordered slice of all section names.
-func (c *Config) Sections() []string {
-	var sections []string
+// publish makes the contents of transaction tx current, records the
+// revision in the history, and notifies the subscribers. Must be called with
+// the writer lock held.
+func (c *Config) publish(tx *Tx) {
+	snap := tx.snapshot(c.Snapshot().revision + 1)
+	c.setOptions(snap)
+	c.snap.Store(snap)
+
+	notify := c.hasSubscriptions()
+	if !notify && c.historySize == 0 {
+		return
+	}
 
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	changes := tx.changes()
+	if c.historySize > 0 {
+		c.record(snap, changes)
+	}
 
-	for section := range c.cfg {
-		sections = append(sections, section)
+	if notify && len(changes) > 0 {
+		c.notify(changes)
 	}
+}
 
-	return sections
+// setOptions sets the options of c in snapshot snap, which must not be
+// published yet. Must be called with the writer lock held.
+func (c *Config) setOptions(snap *Snapshot) {
+	snap.tracker = c.activeTracker()
+	snap.exprs = c.exprs
 }
 
-// SetSection replaces the current contents of section s with a copy of map m.
-func (c *Config) SetSection(s string, m map[string]string) {
-	newM := cloneMap(m)
+// republish publishes the current contents with the current options. Must be
+// called with the writer lock held.
+func (c *Config) republish() {
+	snap := *c.Snapshot()
+	c.setOptions(&snap)
+	c.snap.Store(&snap)
+}
 
+// Update calls fn with a transaction and, if fn returns nil, atomically
+// applies the modifications made through the transaction. If fn returns an
+// error or panics, the modifications are discarded and the error is
+// returned. Writers are serialized: fn runs while holding the writer lock,
+// so it must not modify c other than through the transaction, and the
+// transaction must not be used after fn returns. Readers are never blocked
+// and observe either none or all of the modifications.
+func (c *Config) Update(fn func(tx *Tx) error) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	c.cfg[s] = newM
+	tx := newTx(c.Snapshot())
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	if len(tx.ops) > 0 {
+		c.publish(tx)
+	}
+
+	return nil
 }
 
-// Labels returns an unordered slice of all labels of a section s.
-func (c *Config) Labels(s string) []string {
-	var labels []string
+// update applies a single modification.
+func (c *Config) update(fn func(tx *Tx)) {
+	c.Update(func(tx *Tx) error {
+		fn(tx)
+		return nil
+	})
+}
 
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+// lookup retrieves the value of label l of section s and tests if it exists.
+func (c *Config) lookup(s string, l string) (string, bool) {
+	return c.Snapshot().lookup(s, l)
+}
 
-	for label := range c.cfg[s] {
-		labels = append(labels, label)
-	}
+// Map returns a copy of the configuration contents.
+func (c *Config) Map() map[string]map[string]string {
+	return c.Snapshot().Map()
+}
+
+// SetMap replaces the current configuration with a copy of map m.
+func (c *Config) SetMap(m map[string]map[string]string) {
+	c.update(func(tx *Tx) {
+		tx.SetMap(m)
+	})
+}
 
-	return labels
+// Sections returns an unordered slice of all section names.
+func (c *Config) Sections() []string {
+	return c.Snapshot().Sections()
+}
+
+// SetSection replaces the current contents of section s with a copy of map m.
+func (c *Config) SetSection(s string, m map[string]string) {
+	c.update(func(tx *Tx) {
+		tx.SetSection(s, m)
+	})
+}
+
+// Labels returns an unordered slice of all labels of a section s.
+func (c *Config) Labels(s string) []string {
+	return c.Snapshot().Labels(s)
 }
 
 // Value retrieves the value of label l of section s.
 func (c *Config) Value(s string, l string) string {
-	c.lock.RLock()
-	defer c.lock.RUnlock()
+	return c.Snapshot().Value(s, l)
+}
 
-	return c.cfg[s][l]
+// Source returns the source of the value of label l of section s, see
+// Snapshot.Source.
+func (c *Config) Source(s string, l string) string {
+	return c.Snapshot().Source(s, l)
 }
 
 // SetValue assigns value to label l of section s.
 func (c *Config) SetValue(s string, l string, value string) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	c.setValueNoLock(s, l, value)
+	c.update(func(tx *Tx) {
+		tx.SetValue(s, l, value)
+	})
 }
 
 // AppendValue appends value to label l of section s. The new value will be the concatenation of
 // the old value, sep, and value. If no contents exist this function behaves like SetValue.
 func (c *Config) AppendValue(s string, l string, value string, sep string) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-
-	curValue := c.cfg[s][l]
-	if curValue == "" {
-		c.setValueNoLock(s, l, value)
-	} else {
-		c.setValueNoLock(s, l, curValue+sep+value)
-	}
+	c.update(func(tx *Tx) {
+		tx.AppendValue(s, l, value, sep)
+	})
 }
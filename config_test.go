@@ -19,8 +19,11 @@
 package ini
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 )
 
@@ -133,3 +136,306 @@ func TestSections(t *testing.T) {
 		}
 	}
 }
+
+func TestSnapshot(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("S0", "L0", "V0")
+
+	snap := c.Snapshot()
+	c.SetValue("S0", "L0", "V1")
+	c.SetValue("S1", "L0", "V0")
+	c.SetSection("S0", map[string]string{"L1": "V1"})
+
+	if snap.Value("S0", "L0") != "V0" {
+		t.Errorf("snapshot modified: %v", snap.Map())
+	}
+
+	if len(snap.Sections()) != 1 || len(snap.Labels("S0")) != 1 {
+		t.Errorf("snapshot modified: %v", snap.Map())
+	}
+
+	expected := map[string]map[string]string{
+		"S0": {"L1": "V1"},
+		"S1": {"L0": "V0"},
+	}
+
+	actual := c.Snapshot().Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestZeroConfig(t *testing.T) {
+	var c Config
+	if c.Value("S0", "L0") != "" || len(c.Sections()) != 0 {
+		t.Errorf("expected empty configuration")
+	}
+
+	c.SetValue("S0", "L0", "V0")
+	if c.Value("S0", "L0") != "V0" {
+		t.Errorf("expected: %q, actual: %q", "V0", c.Value("S0", "L0"))
+	}
+}
+
+func TestCommitRebase(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{"S0": {"L0": "A"}})
+
+	tx := c.begin()
+	tx.AppendValue("S0", "L0", "B", " ")
+	tx.SetValue("S1", "L0", "V0")
+
+	// Concurrent modification.
+	c.SetValue("S0", "L0", "C")
+	c.SetValue("S2", "L0", "V0")
+
+	c.commit(tx)
+
+	expected := map[string]map[string]string{
+		"S0": {"L0": "C B"},
+		"S1": {"L0": "V0"},
+		"S2": {"L0": "V0"},
+	}
+
+	actual := c.Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestSetMapCopy(t *testing.T) {
+	m := map[string]map[string]string{"S0": {"L0": "V0"}}
+
+	c := NewConfig()
+	c.SetMap(m)
+	c.SetValue("S0", "L1", "V1")
+	m["S0"]["L0"] = "modified"
+
+	if len(m["S0"]) != 1 || c.Value("S0", "L0") != "V0" {
+		t.Errorf("configuration shares map with caller")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{
+		"Gains": {"Kp": "1", "Ki": "0.1", "Kd": "0.01"},
+		"Old":   {"L0": "V0"},
+	})
+
+	err := c.Update(func(tx *Tx) error {
+		tx.SetValue("Gains", "Kp", "2")
+		tx.AppendValue("Gains", "Ki", "5", "")
+		tx.Remove("Gains", "Kd")
+		tx.RemoveSection("Old")
+
+		if tx.Value("Gains", "Kp") != "2" {
+			t.Errorf("expected: %q, actual: %q", "2", tx.Value("Gains", "Kp"))
+		}
+
+		if c.Value("Gains", "Kp") != "1" {
+			t.Errorf("uncommitted modification visible")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"Gains": {"Kp": "2", "Ki": "0.15"},
+	}
+
+	actual := c.Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("Gains", "Kp", "1")
+	before := c.Snapshot()
+
+	errTest := errors.New("invalid gains")
+	err := c.Update(func(tx *Tx) error {
+		tx.SetValue("Gains", "Kp", "2")
+		tx.SetValue("Gains", "Ki", "3")
+		return errTest
+	})
+	if err != errTest {
+		t.Errorf("expected: %v, actual: %v", errTest, err)
+	}
+
+	func() {
+		defer func() {
+			recover()
+		}()
+
+		c.Update(func(tx *Tx) error {
+			tx.SetValue("Gains", "Kp", "4")
+			panic("test")
+		})
+	}()
+
+	if c.Snapshot() != before {
+		t.Errorf("configuration modified: %v", c.Map())
+	}
+
+	// The writer lock must have been released.
+	c.SetValue("Gains", "Kp", "5")
+	if c.Value("Gains", "Kp") != "5" {
+		t.Errorf("expected: %q, actual: %q", "5", c.Value("Gains", "Kp"))
+	}
+}
+
+func TestUpdateAtomic(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{"S0": {"L0": "0", "L1": "0"}})
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 1; i <= 1000; i++ {
+			value := fmt.Sprint(i)
+			c.Update(func(tx *Tx) error {
+				tx.SetValue("S0", "L0", value)
+				tx.SetValue("S0", "L1", value)
+				return nil
+			})
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+
+		snap := c.Snapshot()
+		if snap.Value("S0", "L0") != snap.Value("S0", "L1") {
+			t.Fatalf("half-updated state: %v", snap.Map())
+		}
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	c := NewConfig()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.AppendValue("S0", "L0", "x", "")
+				c.SetValue(fmt.Sprintf("S%d", i+1), fmt.Sprintf("L%d", j), "V")
+			}
+		}(i)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				snap := c.Snapshot()
+				_ = snap.Map()
+				_ = c.Value("S0", "L0")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(c.Value("S0", "L0")) != 400 {
+		t.Errorf("expected 400 appends, actual: %d", len(c.Value("S0", "L0")))
+	}
+}
+
+// rwConfig is a map guarded by a read-write mutex, used as reference in
+// benchmarks.
+type rwConfig struct {
+	cfg  map[string]map[string]string
+	lock sync.RWMutex
+}
+
+func (c *rwConfig) Value(s string, l string) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.cfg[s][l]
+}
+
+func benchTable() map[string]map[string]string {
+	m := make(map[string]map[string]string)
+	for i := 0; i < 100; i++ {
+		section := make(map[string]string)
+		for j := 0; j < 10; j++ {
+			section[fmt.Sprintf("L%d", j)] = "V"
+		}
+
+		m[fmt.Sprintf("S%d", i)] = section
+	}
+
+	return m
+}
+
+func BenchmarkRWMutexValueParallel(b *testing.B) {
+	c := &rwConfig{cfg: benchTable()}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Value("S50", "L5")
+		}
+	})
+}
+
+func BenchmarkValueParallel(b *testing.B) {
+	c := NewConfig()
+	c.SetMap(benchTable())
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Value("S50", "L5")
+		}
+	})
+}
+
+func BenchmarkSnapshotValueParallel(b *testing.B) {
+	c := NewConfig()
+	c.SetMap(benchTable())
+	snap := c.Snapshot()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			snap.Value("S50", "L5")
+		}
+	})
+}
+
+func BenchmarkValueParallelWithWriter(b *testing.B) {
+	c := NewConfig()
+	c.SetMap(benchTable())
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				c.SetValue("S0", "L0", "V")
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Value("S50", "L5")
+		}
+	})
+}
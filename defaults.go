@@ -0,0 +1,168 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// DefaultSource is the source of the values assigned by ApplyDefaults, see
+// Config.Source.
+const DefaultSource = "default"
+
+// ApplyDefaults assigns the default values of the labels described by
+// schema to the labels not defined in c, in a single transaction. Only
+// sections defined in c are modified. The source of the values assigned is
+// DefaultSource.
+//
+// Defaults of int and float labels that are not plain numbers are evaluated
+// as expressions, which may reference other labels of the same section,
+// e.g., "3 * Period". Referenced labels that are not defined take their own
+// default values. If a default cannot be evaluated, c is not modified and a
+// *Violation describing the label is returned.
+func ApplyDefaults(c *Config, schema *Schema) error {
+	return c.Update(func(tx *Tx) error {
+		tx.SetSource(DefaultSource)
+
+		for _, ss := range schema.Sections {
+			_, exists := tx.cfg[ss.Name]
+			if !exists {
+				continue
+			}
+
+			d := &defaulter{tx, ss, make(map[string]bool)}
+			for _, ls := range ss.Labels {
+				if ls.Default == "" {
+					continue
+				}
+
+				_, err := d.resolve(ls.Name)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// defaulter assigns the default values of a section.
+type defaulter struct {
+	tx        *Tx             // Transaction.
+	ss        *SectionSchema  // Section.
+	resolving map[string]bool // Labels being resolved.
+}
+
+// resolve returns the value of label l, assigning its default if needed.
+func (d *defaulter) resolve(l string) (string, error) {
+	s := d.ss.Name
+	value, exists := d.tx.lookup(s, l)
+	if exists {
+		return value, nil
+	}
+
+	ls := d.ss.Label(l)
+	if ls == nil || ls.Default == "" {
+		return "", &ValueError{s, l, "", ErrNoValue}
+	}
+
+	if d.resolving[l] {
+		return "", &Violation{Section: s, Label: l, Value: ls.Default, Source: DefaultSource, Msg: "circular default"}
+	}
+
+	d.resolving[l] = true
+	defer delete(d.resolving, l)
+
+	value, err := d.evaluate(ls)
+	if err != nil {
+		var violation *Violation
+		if errors.As(err, &violation) {
+			return "", violation
+		}
+
+		return "", &Violation{Section: s, Label: l, Value: ls.Default, Source: DefaultSource,
+			Msg: "invalid default: " + err.Error()}
+	}
+
+	d.tx.SetValue(s, l, value)
+	return value, nil
+}
+
+// evaluate returns the default value of label ls.
+func (d *defaulter) evaluate(ls *LabelSchema) (string, error) {
+	if ls.List || (ls.Type != TypeInt && ls.Type != TypeFloat) {
+		return ls.Default, nil
+	}
+
+	_, err := ls.Number(ls.Default)
+	if err == nil {
+		return ls.Default, nil
+	}
+
+	u, err := ls.unit()
+	if err != nil {
+		return "", err
+	}
+
+	// Expressions are evaluated in SI units.
+	result, err := evalExpr(ls.Default, d.lookupSI)
+	if err != nil {
+		return "", err
+	}
+
+	result /= u.scale
+
+	if ls.Type == TypeInt {
+		if !isInteger(result) {
+			return "", &ExprError{ls.Default, 0, "not an integer: " + strconv.FormatFloat(result, 'g', -1, 64), nil}
+		}
+
+		return strconv.FormatInt(int64(math.Round(result)), 10), nil
+	}
+
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+// lookupSI returns the value of label l converted to SI units, resolving
+// its default if needed.
+func (d *defaulter) lookupSI(l string) (float64, error) {
+	value, err := d.resolve(l)
+	if err != nil {
+		return 0, err
+	}
+
+	def := dimensionless
+	ls := d.ss.Label(l)
+	if ls != nil && (ls.Type == TypeInt || ls.Type == TypeFloat) {
+		def, err = ls.unit()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	number, _, err := parseMeasure(value, def)
+	if err != nil {
+		return 0, &ValueError{d.ss.Name, l, value, err}
+	}
+
+	return number, nil
+}
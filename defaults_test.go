@@ -0,0 +1,135 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	schema, err := ReadSchema(strings.NewReader(`
+[Control/Period]
+Type    = float
+Default = 1/20
+
+[Control/Timeout]
+Type    = float
+Default = 3 * Period
+
+[Control/Retries]
+Type    = int
+Default = Timeout * 20
+
+[Control/Mode]
+Default = Depth
+
+[Control/Gains]
+Type    = float
+List    = true
+Default = 1, 2
+
+[Control/Frequency]
+Type    = int
+Default = 0x10
+
+[Optional/Enabled]
+Type    = bool
+Default = false
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := NewConfig()
+	c.Update(func(tx *Tx) error {
+		tx.SetSource("vehicle.ini:2")
+		tx.SetValue("Control", "Period", "0.1")
+		tx.SetValue("Control", "Mode", "Altitude")
+		return nil
+	})
+
+	err = ApplyDefaults(c, schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"Control": {
+			"Period":    "0.1",
+			"Timeout":   "0.30000000000000004",
+			"Retries":   "6",
+			"Mode":      "Altitude",
+			"Gains":     "1, 2",
+			"Frequency": "0x10",
+		},
+	}
+
+	if !reflect.DeepEqual(expected, c.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, c.Map())
+	}
+
+	if c.Source("Control", "Period") != "vehicle.ini:2" || c.Source("Control", "Timeout") != DefaultSource {
+		t.Errorf("unexpected sources: %q, %q", c.Source("Control", "Period"), c.Source("Control", "Timeout"))
+	}
+
+	if len(Validate(c, schema)) != 0 {
+		t.Errorf("unexpected violations: %v", Validate(c, schema))
+	}
+}
+
+func TestApplyDefaultsErrors(t *testing.T) {
+	tests := []struct {
+		schema   string
+		expected string
+	}{
+		{"[S0/A]\nType = float\nDefault = 2 * B\n[S0/B]\nType = float\nDefault = A\n",
+			"default: S0: A: circular default"},
+		{"[S0/A]\nType = int\nDefault = 1 / 3\n",
+			`default: S0: A: invalid default: expression "1 / 3": column 1: not an integer: 0.3333333333333333`},
+		{"[S0/A]\nType = float\nDefault = 2 * Missing\n",
+			`default: S0: A: invalid default: expression "2 * Missing": column 5: S0: Missing: no value`},
+		{"[S0/A]\nType = float\nDefault = 2 * Text\n",
+			`default: S0: A: invalid default: expression "2 * Text": column 5: S0: Text: invalid value "abc": invalid syntax`},
+	}
+
+	for _, test := range tests {
+		schema, err := ReadSchema(strings.NewReader(test.schema))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		c := NewConfig()
+		c.SetValue("S0", "Text", "abc")
+		before := c.Snapshot()
+
+		err = ApplyDefaults(c, schema)
+
+		var violation *Violation
+		if !errors.As(err, &violation) || err.Error() != test.expected {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+
+		if c.Snapshot() != before {
+			t.Errorf("configuration modified: %v", c.Map())
+		}
+	}
+}
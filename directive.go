@@ -0,0 +1,234 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Directive is a directive in square brackets, e.g., [Define Name Value],
+// passed to the function registered to execute it. The values parsed are
+// published to the Config of the parser only when the parse ends, so the
+// function must read and modify them through Tx.
+type Directive struct {
+	Name    string   // Name.
+	Args    string   // Arguments, without surrounding spaces.
+	Pos     Position // Position of the directive.
+	Section string   // Section being parsed, empty if none.
+	Parser  *Parser  // Parser executing the directive.
+	Tx      *Tx      // Transaction holding the values parsed so far.
+}
+
+// Errorf returns a *SyntaxError at the line of the directive.
+func (d *Directive) Errorf(format string, args ...any) error {
+	return &SyntaxError{d.Pos.File, d.Pos.LineNr, fmt.Sprintf(format, args...)}
+}
+
+// Warn reports a warning at the line of the directive, see Parser.OnWarning.
+func (d *Directive) Warn(msg string) {
+	d.Parser.logger().Warn("directive warning", "file", d.Pos.File, "line", d.Pos.LineNr,
+		"directive", d.Name, "msg", msg)
+	d.Parser.warn(msg)
+}
+
+// DirectiveFunc executes a directive. An error stops the parse.
+type DirectiveFunc func(d *Directive) error
+
+// builtinDirectives are the directives recognized by all parsers, by name.
+var builtinDirectives map[string]DirectiveFunc
+
+func init() {
+	builtinDirectives = map[string]DirectiveFunc{
+		"Require": func(d *Directive) error {
+			return d.Parser.includeFiles(d.Args, true)
+		},
+		"Include": func(d *Directive) error {
+			return d.Parser.includeFiles(d.Args, false)
+		},
+	}
+}
+
+// standardDirectives are the directives registered by
+// RegisterStandardDirectives, by name.
+var standardDirectives = map[string]DirectiveFunc{
+	"Define":  defineDirective,
+	"Error":   errorDirective,
+	"Warning": warningDirective,
+}
+
+// bareDirectives holds the names of the built-in directives without
+// arguments.
+var bareDirectives = []string{"Else", "EndIf"}
+
+// RegisterDirective registers function fn to execute directive name, e.g.,
+// [name args], replacing the function registered before, if any, or the
+// built-in directive. A nil fn removes the registration. The directives of
+// conditional blocks cannot be replaced. Directives must not be registered
+// during a parse.
+func (p *Parser) RegisterDirective(name string, fn DirectiveFunc) {
+	if name == "" || strings.ContainsAny(name, " \t") || isCondDirective(name) {
+		panic("ini: invalid directive name: " + name)
+	}
+
+	if p.directives == nil {
+		p.directives = make(map[string]DirectiveFunc)
+	}
+
+	if fn == nil {
+		delete(p.directives, name)
+	} else {
+		p.directives[name] = fn
+	}
+}
+
+// RegisterStandardDirectives registers the directives [Define Name Value],
+// which defines a variable for the rest of the parse, overriding Variables,
+// [Error "Message"], which stops the parse with an error, and
+// [Warning "Message"], which reports a warning. Sections named after them,
+// e.g., [Error Handling], are then parsed as directives.
+func (p *Parser) RegisterStandardDirectives() {
+	for name, fn := range standardDirectives {
+		p.RegisterDirective(name, fn)
+	}
+}
+
+// lookupDirective returns the function executing directive name.
+func (p *Parser) lookupDirective(name string) (DirectiveFunc, bool) {
+	fn, ok := p.directives[name]
+	if !ok {
+		fn, ok = builtinDirectives[name]
+	}
+
+	return fn, ok
+}
+
+// isBuiltinInclude tests if directive name is the built-in Require or
+// Include directive, not overridden with RegisterDirective.
+func (p *Parser) isBuiltinInclude(name string) bool {
+	_, overridden := p.directives[name]
+	return !overridden && (name == "Require" || name == "Include")
+}
+
+// splitDirective splits the text in square brackets into a directive name
+// and its arguments, if it starts with the name of a directive followed by a
+// space, or is the name of a directive without arguments. Only registered
+// directives may be used without arguments, so that sections named after
+// built-in directives remain valid.
+func (p *Parser) splitDirective(text string) (string, string, bool) {
+	if slices.Contains(bareDirectives, text) {
+		return text, "", true
+	}
+
+	if _, registered := p.directives[text]; registered {
+		return text, "", true
+	}
+
+	name, args, ok := strings.Cut(text, " ")
+	if !ok {
+		return "", "", false
+	}
+
+	if _, registered := p.lookupDirective(name); !registered && !isCondDirective(name) {
+		return "", "", false
+	}
+
+	return name, strings.TrimSpace(args), true
+}
+
+func (p *Parser) handleDirective(name string, args string, col int) error {
+	fn, _ := p.lookupDirective(name)
+	p.tx.SetSource(p.curSource())
+	return fn(&Directive{
+		Name:    name,
+		Args:    args,
+		Pos:     Position{p.curFile(), p.curLineNr(), col},
+		Section: p.curSection,
+		Parser:  p,
+		Tx:      p.tx,
+	})
+}
+
+// Variable returns the value of variable name, defined by a Define
+// directive of the current parse or by Variables, in that order.
+func (p *Parser) Variable(name string) (string, bool) {
+	value, ok := p.defines[name]
+	if !ok {
+		value, ok = p.Variables[name]
+	}
+
+	return value, ok
+}
+
+// Define assigns value to variable name for the rest of the current parse.
+func (p *Parser) Define(name string, value string) {
+	if p.defines == nil {
+		p.defines = make(map[string]string)
+	}
+
+	p.defines[name] = value
+}
+
+// unquote removes the double quotes around s, if any.
+func unquote(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err == nil {
+			return unquoted
+		}
+	}
+
+	return s
+}
+
+// defineDirective executes [Define Name Value], which defines a variable for
+// the rest of the parse.
+func defineDirective(d *Directive) error {
+	name, value, _ := strings.Cut(d.Args, " ")
+	if name == "" {
+		return d.Errorf("missing variable name")
+	}
+
+	d.Parser.Define(name, unquote(strings.TrimSpace(value)))
+	return nil
+}
+
+// errorDirective executes [Error "message"], which stops the parse with an
+// error.
+func errorDirective(d *Directive) error {
+	msg := unquote(d.Args)
+	if msg == "" {
+		msg = "error directive"
+	}
+
+	return d.Errorf("%s", msg)
+}
+
+// warningDirective executes [Warning "message"], which reports a warning.
+func warningDirective(d *Directive) error {
+	msg := unquote(d.Args)
+	if msg == "" {
+		msg = "warning directive"
+	}
+
+	d.Warn(msg)
+	return nil
+}
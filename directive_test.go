@@ -0,0 +1,263 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefine(t *testing.T) {
+	p := NewParser(nil)
+	p.RegisterStandardDirectives()
+	p.Variables = map[string]string{"Profile": "Hardware"}
+	err := p.Parse(strings.NewReader(`[Define Profile Simulation]
+[Define Name "lauv xplore"]
+[If Profile == Simulation]
+[General]
+Speed = 1.0
+[EndIf]
+[If Name == lauv xplore]
+[General]
+Name = Defined
+[EndIf]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"General": {"Speed": "1.0", "Name": "Defined"}}
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+
+	// Definitions do not outlive the parse.
+	err = p.Parse(strings.NewReader("[If Profile == Simulation]\n[Other]\n[EndIf]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, exists := p.Config.Map()["Other"]; exists {
+		t.Errorf("unexpected configuration: %v", p.Config.Map())
+	}
+}
+
+func TestErrorWarningDirectives(t *testing.T) {
+	var warnings []string
+
+	p := NewParser(nil)
+	p.RegisterStandardDirectives()
+	p.Variables = map[string]string{"Profile": "Old"}
+	p.OnWarning = func(w *Warning) {
+		warnings = append(warnings, w.Error())
+	}
+
+	err := p.Parse(strings.NewReader(`[If Profile == Old]
+[Warning "profile is deprecated"]
+[EndIf]
+[If Profile == Unsupported]
+[Error "profile is not supported"]
+[EndIf]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"2: warning: profile is deprecated"}
+	if !reflect.DeepEqual(expected, warnings) {
+		t.Errorf("expected: %q, actual: %q", expected, warnings)
+	}
+
+	p.Variables["Profile"] = "Unsupported"
+	err = p.Parse(strings.NewReader("[S0]\n[If Profile == Unsupported]\n[Error \"profile is not supported\"]\n[EndIf]\n"))
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || err.Error() != "3: profile is not supported" {
+		t.Errorf("expected: %q, actual: %v", "3: profile is not supported", err)
+	}
+}
+
+func TestStandardDirectivesOptIn(t *testing.T) {
+	input := "[Error Handling]\nRetries = 3\n[Warning Lights]\nEnabled = true\n[Define Mission]\nName = Survey\n"
+	expected := map[string]map[string]string{
+		"Error Handling": {"Retries": "3"},
+		"Warning Lights": {"Enabled": "true"},
+		"Define Mission": {"Name": "Survey"},
+	}
+
+	p := NewParser(nil)
+	p.OnWarning = func(w *Warning) {
+		t.Errorf("unexpected warning: %v", w)
+	}
+
+	err := p.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+
+	p = NewParser(nil)
+	p.RegisterStandardDirectives()
+	err = p.Parse(strings.NewReader(input))
+	if err == nil || err.Error() != "1: Handling" {
+		t.Errorf("expected: %q, actual: %v", "1: Handling", err)
+	}
+}
+
+func TestRegisterDirective(t *testing.T) {
+	var directives []Directive
+
+	set := func(d *Directive) error {
+		directives = append(directives, Directive{Name: d.Name, Args: d.Args, Pos: d.Pos, Section: d.Section})
+
+		label, value, _ := strings.Cut(d.Args, "=")
+		if d.Section == "" {
+			return d.Errorf("Set outside section")
+		}
+
+		d.Tx.SetValue(d.Section, strings.TrimSpace(label), strings.TrimSpace(value))
+		return nil
+	}
+
+	p := NewParser(nil)
+	p.RegisterDirective("Set", set)
+	p.RegisterDirective("Stop", func(d *Directive) error {
+		return d.Errorf("stopped")
+	})
+
+	err := p.Parse(strings.NewReader("[S0]\n  [Set L0 = V0]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Directive{{Name: "Set", Args: "L0 = V0", Pos: Position{"", 2, 4}, Section: "S0"}}
+	if !reflect.DeepEqual(expected, directives) {
+		t.Errorf("expected: %+v, actual: %+v", expected, directives)
+	}
+
+	if p.Config.Value("S0", "L0") != "V0" || p.Config.Source("S0", "L0") != "2" {
+		t.Errorf("unexpected value: %q from %q", p.Config.Value("S0", "L0"), p.Config.Source("S0", "L0"))
+	}
+
+	other := NewParser(nil)
+	other.RegisterDirective("Set", set)
+	err = other.Parse(strings.NewReader("[Set L0 = V0]\n"))
+	if err == nil || err.Error() != "1: Set outside section" {
+		t.Errorf("expected: %q, actual: %v", "1: Set outside section", err)
+	}
+
+	err = p.Parse(strings.NewReader("[S0]\n[Stop]\n"))
+	if err == nil || err.Error() != "2: stopped" {
+		t.Errorf("expected: %q, actual: %v", "2: stopped", err)
+	}
+
+	// Removing a directive restores sections with its name.
+	p.RegisterDirective("Stop", nil)
+	err = p.Parse(strings.NewReader("[Stop]\nL0 = V0\n"))
+	if err != nil || p.Config.Value("Stop", "L0") != "V0" {
+		t.Errorf("expected section named Stop: %v, %v", err, p.Config.Map())
+	}
+}
+
+func TestDirectiveTx(t *testing.T) {
+	p := NewParser(nil)
+	p.RegisterDirective("Double", func(d *Directive) error {
+		if d.Parser.Config.Value(d.Section, d.Args) != "" {
+			t.Errorf("values published during the parse")
+		}
+
+		value := d.Tx.Value(d.Section, d.Args)
+		d.Tx.SetValue(d.Section, d.Args, value+value)
+		return nil
+	})
+
+	err := p.Parse(strings.NewReader("[S0]\nx = 1\ny = parsed\n[Double x]\n[Double y]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"S0": {"x": "11", "y": "parsedparsed"}}
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+}
+
+func TestRegisterDirectiveOverride(t *testing.T) {
+	var targets []string
+
+	p := NewParser(nil)
+	p.RegisterDirective("Include", func(d *Directive) error {
+		targets = append(targets, d.Args)
+		return nil
+	})
+
+	err := p.Parse(strings.NewReader("[Include missing.ini]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"missing.ini"}
+	if !reflect.DeepEqual(expected, targets) {
+		t.Errorf("expected: %q, actual: %q", expected, targets)
+	}
+}
+
+func TestRegisterDirectiveInvalid(t *testing.T) {
+	for _, name := range []string{"", "If", "EndIf", "Two Words"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic registering %q", name)
+				}
+			}()
+
+			NewParser(nil).RegisterDirective(name, func(*Directive) error { return nil })
+		}()
+	}
+}
+
+func TestScanCustomDirective(t *testing.T) {
+	p := NewParser(nil)
+	p.RegisterStandardDirectives()
+	p.RegisterDirective("Stop", func(d *Directive) error {
+		return d.Errorf("stopped")
+	})
+
+	var events []Event
+	err := p.Scan(strings.NewReader("[Stop]\n[Define A 1]\n"), func(ev Event) error {
+		events = append(events, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Event{
+		{Kind: DirectiveEvent, Pos: Position{"", 1, 2}, Directive: "Stop"},
+		{Kind: DirectiveEvent, Pos: Position{"", 2, 2}, Directive: "Define", Value: "A 1"},
+	}
+
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("\nexpected: %+v\nactual: %+v", expected, events)
+	}
+}
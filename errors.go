@@ -18,7 +18,10 @@
 
 package ini
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // SyntaxError represents a parsing error.
 type SyntaxError struct {
@@ -35,3 +38,139 @@ func (e *SyntaxError) Error() string {
 
 	return fmt.Sprintf("%s:%d: %s", e.path, e.lineNr, e.msg)
 }
+
+// ReadError represents a failure to read from the underlying stream.
+type ReadError struct {
+	path   string // File path.
+	lineNr uint   // Line number being read.
+	err    error  // Underlying error.
+}
+
+// Error formats the error to a human readable sentence.
+func (e *ReadError) Error() string {
+	if e.path == "" {
+		return fmt.Sprintf("%d: read error: %v", e.lineNr, e.err)
+	}
+
+	return fmt.Sprintf("%s:%d: read error: %v", e.path, e.lineNr, e.err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ReadError) Unwrap() error {
+	return e.err
+}
+
+// Warning represents a non-fatal problem found while parsing.
+type Warning struct {
+	path   string // File path.
+	lineNr uint   // Line number.
+	msg    string // Warning description.
+}
+
+// Error formats the warning to a human readable sentence.
+func (w *Warning) Error() string {
+	if w.path == "" {
+		return fmt.Sprintf("%d: warning: %s", w.lineNr, w.msg)
+	}
+
+	return fmt.Sprintf("%s:%d: warning: %s", w.path, w.lineNr, w.msg)
+}
+
+// Errors describing the resource limit exceeded by a parse. See Limits.
+var (
+	ErrLineLength   = errors.New("line too long")
+	ErrBytes        = errors.New("too many bytes")
+	ErrIncludeDepth = errors.New("include depth exceeded")
+	ErrFiles        = errors.New("too many files")
+	ErrSections     = errors.New("too many sections")
+	ErrLabels       = errors.New("too many labels")
+)
+
+// LimitError represents a parse that exceeded one of its resource limits.
+// The limit can be identified with errors.Is, e.g. errors.Is(err,
+// ErrLineLength).
+type LimitError struct {
+	path   string // File path.
+	lineNr uint   // Line number.
+	err    error  // Limit exceeded.
+}
+
+// Error formats the error to a human readable sentence.
+func (e *LimitError) Error() string {
+	if e.path == "" {
+		return fmt.Sprintf("%d: %v", e.lineNr, e.err)
+	}
+
+	return fmt.Sprintf("%s:%d: %v", e.path, e.lineNr, e.err)
+}
+
+// Unwrap returns the limit exceeded.
+func (e *LimitError) Unwrap() error {
+	return e.err
+}
+
+// SecurityError represents an include directive rejected by the sandbox.
+type SecurityError struct {
+	path   string // File path.
+	lineNr uint   // Line number.
+	msg    string // Error description.
+}
+
+// Error formats the error to a human readable sentence.
+func (e *SecurityError) Error() string {
+	if e.path == "" {
+		return fmt.Sprintf("%d: %s", e.lineNr, e.msg)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.path, e.lineNr, e.msg)
+}
+
+// ErrNoValue is returned by typed getters when a label does not exist.
+var ErrNoValue = errors.New("no value")
+
+// ValueError represents a value that cannot be converted to the type
+// requested from a typed getter. Missing values wrap ErrNoValue.
+type ValueError struct {
+	section string // Section name.
+	label   string // Label.
+	value   string // Value.
+	err     error  // Underlying error.
+}
+
+// Error formats the error to a human readable sentence.
+func (e *ValueError) Error() string {
+	if e.err == ErrNoValue {
+		return fmt.Sprintf("%s: %s: %v", e.section, e.label, e.err)
+	}
+
+	return fmt.Sprintf("%s: %s: invalid value %q: %v", e.section, e.label, e.value, e.err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ValueError) Unwrap() error {
+	return e.err
+}
+
+// ExprError represents an invalid numeric expression.
+type ExprError struct {
+	expr string // Expression.
+	pos  int    // Byte offset of the error in expr.
+	msg  string // Error description.
+	err  error  // Underlying error, may be nil.
+}
+
+// Error formats the error to a human readable sentence.
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("expression %q: column %d: %s", e.expr, e.pos+1, e.msg)
+}
+
+// Column returns the position of the error in the expression, starting at
+// 1.
+func (e *ExprError) Column() int {
+	return e.pos + 1
+}
+
+// Unwrap returns the underlying error.
+func (e *ExprError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,329 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// exprParser evaluates numeric expressions with the grammar:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = ("+" | "-") unary | primary
+//	primary = number | label | function "(" [ expr { "," expr } ] ")" | "(" expr ")"
+//
+// Labels are sequences of words separated by spaces, e.g., "Max Speed",
+// and are resolved with function lookup. The functions available are
+// listed in exprFuncs.
+type exprParser struct {
+	expr   string                              // Expression.
+	pos    int                                 // Current byte offset.
+	lookup func(label string) (float64, error) // Resolves labels.
+}
+
+// evalExpr evaluates numeric expression expr, resolving labels with
+// function lookup.
+func evalExpr(expr string, lookup func(label string) (float64, error)) (float64, error) {
+	ep := &exprParser{expr: expr, lookup: lookup}
+
+	result, err := ep.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+
+	ep.skipSpace()
+	if ep.pos < len(ep.expr) {
+		return 0, ep.errorf(ep.pos, "unexpected %q", ep.expr[ep.pos])
+	}
+
+	return result, nil
+}
+
+func (ep *exprParser) errorf(pos int, format string, args ...any) error {
+	return &ExprError{ep.expr, pos, fmt.Sprintf(format, args...), nil}
+}
+
+func (ep *exprParser) skipSpace() {
+	for ep.pos < len(ep.expr) && (ep.expr[ep.pos] == ' ' || ep.expr[ep.pos] == '\t') {
+		ep.pos++
+	}
+}
+
+// peek returns the next non-space character, or zero at the end.
+func (ep *exprParser) peek() byte {
+	ep.skipSpace()
+	if ep.pos < len(ep.expr) {
+		return ep.expr[ep.pos]
+	}
+
+	return 0
+}
+
+func (ep *exprParser) parseExpr() (float64, error) {
+	result, err := ep.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op := ep.peek()
+		if op != '+' && op != '-' {
+			return result, nil
+		}
+
+		ep.pos++
+		operand, err := ep.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == '+' {
+			result += operand
+		} else {
+			result -= operand
+		}
+	}
+}
+
+func (ep *exprParser) parseTerm() (float64, error) {
+	result, err := ep.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op := ep.peek()
+		if op != '*' && op != '/' {
+			return result, nil
+		}
+
+		opPos := ep.pos
+		ep.pos++
+		operand, err := ep.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == '*' {
+			result *= operand
+		} else if operand == 0 {
+			return 0, ep.errorf(opPos, "division by zero")
+		} else {
+			result /= operand
+		}
+	}
+}
+
+func (ep *exprParser) parseUnary() (float64, error) {
+	switch ep.peek() {
+	case '-':
+		ep.pos++
+		result, err := ep.parseUnary()
+		return -result, err
+	case '+':
+		ep.pos++
+		return ep.parseUnary()
+	}
+
+	return ep.parsePrimary()
+}
+
+func (ep *exprParser) parsePrimary() (float64, error) {
+	c := ep.peek()
+	switch {
+	case c == 0:
+		return 0, ep.errorf(ep.pos, "unexpected end of expression")
+	case c == '(':
+		openPos := ep.pos
+		ep.pos++
+		result, err := ep.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+
+		if ep.peek() != ')' {
+			return 0, ep.errorf(openPos, "unbalanced parenthesis")
+		}
+
+		ep.pos++
+		return result, nil
+	case isDigit(c) || c == '.':
+		return ep.parseNumber()
+	case isWordChar(c):
+		return ep.parseLabel()
+	}
+
+	return 0, ep.errorf(ep.pos, "unexpected %q", c)
+}
+
+func (ep *exprParser) parseNumber() (float64, error) {
+	start := ep.pos
+	for ep.pos < len(ep.expr) {
+		c := ep.expr[ep.pos]
+		if isDigit(c) || c == '.' {
+			ep.pos++
+		} else if (c == 'e' || c == 'E') && ep.pos+1 < len(ep.expr) {
+			ep.pos++
+			if ep.expr[ep.pos] == '+' || ep.expr[ep.pos] == '-' {
+				ep.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	result, err := strconv.ParseFloat(ep.expr[start:ep.pos], 64)
+	if err != nil || math.IsInf(result, 0) {
+		return 0, ep.errorf(start, "invalid number %q", ep.expr[start:ep.pos])
+	}
+
+	return result, nil
+}
+
+// scanLabel returns the label starting at the current position: words
+// separated by single or multiple spaces.
+func (ep *exprParser) scanLabel() string {
+	start := ep.pos
+	end := ep.pos
+	for ep.pos < len(ep.expr) {
+		c := ep.expr[ep.pos]
+		if isWordChar(c) || isDigit(c) {
+			ep.pos++
+			end = ep.pos
+		} else if c == ' ' || c == '\t' {
+			ep.pos++
+		} else {
+			break
+		}
+	}
+
+	ep.pos = end
+	return ep.expr[start:end]
+}
+
+func (ep *exprParser) parseLabel() (float64, error) {
+	start := ep.pos
+	label := ep.scanLabel()
+
+	if ep.peek() == '(' {
+		return ep.parseCall(start, label)
+	}
+
+	if ep.lookup == nil {
+		return 0, ep.errorf(start, "unknown label %q", label)
+	}
+
+	result, err := ep.lookup(label)
+	if err != nil {
+		return 0, &ExprError{ep.expr, start, err.Error(), err}
+	}
+
+	return result, nil
+}
+
+// exprFunc is a function available in expressions.
+type exprFunc struct {
+	nrArgs int                       // Number of arguments.
+	fn     func(x []float64) float64 // Implementation.
+}
+
+func unaryFunc(fn func(float64) float64) exprFunc {
+	return exprFunc{1, func(x []float64) float64 { return fn(x[0]) }}
+}
+
+func binaryFunc(fn func(float64, float64) float64) exprFunc {
+	return exprFunc{2, func(x []float64) float64 { return fn(x[0], x[1]) }}
+}
+
+// exprFuncs are the functions available in expressions, by name.
+var exprFuncs = map[string]exprFunc{
+	"sin":     unaryFunc(math.Sin),
+	"cos":     unaryFunc(math.Cos),
+	"tan":     unaryFunc(math.Tan),
+	"asin":    unaryFunc(math.Asin),
+	"acos":    unaryFunc(math.Acos),
+	"atan":    unaryFunc(math.Atan),
+	"atan2":   binaryFunc(math.Atan2),
+	"sqrt":    unaryFunc(math.Sqrt),
+	"abs":     unaryFunc(math.Abs),
+	"exp":     unaryFunc(math.Exp),
+	"log":     unaryFunc(math.Log),
+	"pow":     binaryFunc(math.Pow),
+	"min":     binaryFunc(math.Min),
+	"max":     binaryFunc(math.Max),
+	"deg2rad": unaryFunc(func(x float64) float64 { return x * math.Pi / 180 }),
+	"rad2deg": unaryFunc(func(x float64) float64 { return x * 180 / math.Pi }),
+}
+
+// parseCall parses the arguments of function name, which starts at byte
+// offset start, and calls it.
+func (ep *exprParser) parseCall(start int, name string) (float64, error) {
+	f, exists := exprFuncs[name]
+	if !exists {
+		return 0, ep.errorf(start, "unknown function %q", name)
+	}
+
+	openPos := ep.pos
+	ep.pos++
+
+	var args []float64
+	if ep.peek() != ')' {
+		for {
+			arg, err := ep.parseExpr()
+			if err != nil {
+				return 0, err
+			}
+
+			args = append(args, arg)
+			if ep.peek() != ',' {
+				break
+			}
+
+			ep.pos++
+		}
+	}
+
+	if ep.peek() != ')' {
+		return 0, ep.errorf(openPos, "unbalanced parenthesis")
+	}
+
+	ep.pos++
+
+	if len(args) != f.nrArgs {
+		return 0, ep.errorf(start, "wrong number of arguments to %s: expected %d, got %d", name, f.nrArgs, len(args))
+	}
+
+	result := f.fn(args)
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, ep.errorf(start, "%s: argument out of domain", name)
+	}
+
+	return result, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,110 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	labels := map[string]float64{"Period": 0.05, "Max Speed": 2, "Gain2": 4}
+	lookup := func(label string) (float64, error) {
+		value, exists := labels[label]
+		if !exists {
+			return 0, ErrNoValue
+		}
+
+		return value, nil
+	}
+
+	tests := []struct {
+		expr     string
+		expected float64
+	}{
+		{"1", 1},
+		{" 1/20 ", 0.05},
+		{"0.35 * 2", 0.7},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"2 - 3 - 4", -5},
+		{"8 / 4 / 2", 1},
+		{"-(2 + 1) * -2", 6},
+		{"+.5e1", 5},
+		{"1.5E-1 * 2", 0.3},
+		{"3 * Period", 0.15000000000000002},
+		{"Max Speed/Gain2", 0.5},
+		{"(Max Speed)", 2},
+		{"sqrt(16)", 4},
+		{"2 * sin(deg2rad(90))", 2},
+		{"cos (0) + abs(-Gain2)", 5},
+		{"max(1, min(Period, 3))", 1},
+		{"pow(2, 10)", 1024},
+		{"rad2deg(atan2(1, 1))", 45},
+	}
+
+	for _, test := range tests {
+		actual, err := evalExpr(test.expr, lookup)
+		if err != nil || actual != test.expected {
+			t.Errorf("%s: expected: %v, actual: %v, %v", test.expr, test.expected, actual, err)
+		}
+	}
+}
+
+func TestEvalExprErrors(t *testing.T) {
+	lookup := func(label string) (float64, error) {
+		return 0, ErrNoValue
+	}
+
+	tests := []struct {
+		expr     string
+		column   int
+		expected string
+	}{
+		{"", 1, `expression "": column 1: unexpected end of expression`},
+		{"1 +", 4, `expression "1 +": column 4: unexpected end of expression`},
+		{"2 * (3 + 4", 5, `expression "2 * (3 + 4": column 5: unbalanced parenthesis`},
+		{"2 3", 3, `expression "2 3": column 3: unexpected '3'`},
+		{"1 / (2 - 2)", 3, `expression "1 / (2 - 2)": column 3: division by zero`},
+		{"1 + 1.2.3", 5, `expression "1 + 1.2.3": column 5: invalid number "1.2.3"`},
+		{"1 $ 2", 3, `expression "1 $ 2": column 3: unexpected '$'`},
+		{"1 + Speed", 5, `expression "1 + Speed": column 5: no value`},
+		{"1e999", 1, `expression "1e999": column 1: invalid number "1e999"`},
+		{"2 * sqrt(-1)", 5, `expression "2 * sqrt(-1)": column 5: sqrt: argument out of domain`},
+		{"1 + cosh(2)", 5, `expression "1 + cosh(2)": column 5: unknown function "cosh"`},
+		{"sin(1, 2)", 1, `expression "sin(1, 2)": column 1: wrong number of arguments to sin: expected 1, got 2`},
+		{"pow()", 1, `expression "pow()": column 1: wrong number of arguments to pow: expected 2, got 0`},
+		{"sqrt(2", 5, `expression "sqrt(2": column 5: unbalanced parenthesis`},
+		{"sqrt(2 +)", 9, `expression "sqrt(2 +)": column 9: unexpected ')'`},
+	}
+
+	for _, test := range tests {
+		_, err := evalExpr(test.expr, lookup)
+
+		var exprErr *ExprError
+		if !errors.As(err, &exprErr) || exprErr.Column() != test.column || err.Error() != test.expected {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+	}
+
+	_, err := evalExpr("Speed", lookup)
+	if !errors.Is(err, ErrNoValue) {
+		t.Errorf("expected: %v, actual: %v", ErrNoValue, err)
+	}
+}
@@ -0,0 +1,179 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fileSystem provides the file access and path manipulation routines used to
+// read and resolve configuration files.
+type fileSystem interface {
+	// Open opens file name for reading.
+	Open(name string) (fs.File, error)
+	// Stat returns information about file name.
+	Stat(name string) (fs.FileInfo, error)
+	// ReadDir reads folder name and returns its entries sorted by name.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Join joins path elements into a single path.
+	Join(elem ...string) string
+	// Dir returns all but the last element of path.
+	Dir(name string) string
+	// IsAbs tests if path is absolute.
+	IsAbs(name string) bool
+	// Key returns a string uniquely identifying file name.
+	Key(name string) string
+	// Split splits pattern into its root folder and the remaining elements.
+	Split(pattern string) (string, []string)
+	// Within tests if file name is lexically within folder root.
+	Within(root string, name string) bool
+	// EvalSymlinks returns file name after following symbolic links.
+	EvalSymlinks(name string) (string, error)
+}
+
+// hostFS is the file system of the host, using native file paths.
+type hostFS struct{}
+
+func (hostFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (hostFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (hostFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (hostFS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (hostFS) Dir(name string) string {
+	return filepath.Dir(name)
+}
+
+func (hostFS) IsAbs(name string) bool {
+	return filepath.IsAbs(name)
+}
+
+func (hostFS) Key(name string) string {
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return name
+	}
+
+	return absName
+}
+
+func (hostFS) Split(pattern string) (string, []string) {
+	elems := strings.Split(filepath.Clean(pattern), string(filepath.Separator))
+	if elems[0] == "" {
+		return string(filepath.Separator), elems[1:]
+	}
+
+	return ".", elems
+}
+
+func (hostFS) Within(root string, name string) bool {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absRoot, absName)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (hostFS) EvalSymlinks(name string) (string, error) {
+	return filepath.EvalSymlinks(name)
+}
+
+// ioFS adapts an fs.FS. Paths are slash-separated and absolute paths are
+// taken relative to the root of the file system.
+type ioFS struct {
+	fsys fs.FS
+}
+
+func (f ioFS) Open(name string) (fs.File, error) {
+	return f.fsys.Open(name)
+}
+
+func (f ioFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.fsys, name)
+}
+
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(f.fsys, name)
+}
+
+func (ioFS) Join(elem ...string) string {
+	name := strings.TrimPrefix(path.Join(elem...), "/")
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
+func (ioFS) Dir(name string) string {
+	return path.Dir(name)
+}
+
+func (ioFS) IsAbs(name string) bool {
+	return path.IsAbs(name)
+}
+
+func (f ioFS) Key(name string) string {
+	return f.Join(name)
+}
+
+func (f ioFS) Split(pattern string) (string, []string) {
+	return ".", strings.Split(f.Join(pattern), "/")
+}
+
+func (f ioFS) Within(root string, name string) bool {
+	root = f.Join(root)
+	name = f.Join(name)
+
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return false
+	}
+
+	return root == "." || name == root || strings.HasPrefix(name, root+"/")
+}
+
+// EvalSymlinks returns name, symbolic links are not supported by io/fs.
+func (ioFS) EvalSymlinks(name string) (string, error) {
+	return name, nil
+}
@@ -0,0 +1,265 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func newMapFile(data string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini": newMapFile("[main]\nL0 = V0\n" +
+			"[Require sub/required.ini]\n" +
+			"[Include sub/optional.ini]\n" +
+			"[Include sub/__no_such_file]\n"),
+		"sub/required.ini": newMapFile("[required]\nL0 = V0\n[Require ../common.ini]\n"),
+		"sub/optional.ini": newMapFile("[optional]\nL0 = V0\n[Require /common.ini]\n"),
+		"common.ini":       newMapFile("[common]\nL0 += V0\n"),
+	}
+
+	p := NewParser(nil)
+	err := p.ParseFS(fsys, "main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"main":     {"L0": "V0"},
+		"required": {"L0": "V0"},
+		"optional": {"L0": "V0"},
+		"common":   {"L0": "V0 V0"},
+	}
+
+	actual := p.Config.Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("\nexpected: %q\nactual: %q", expected, actual)
+	}
+}
+
+func TestParseFSNotFound(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini": newMapFile("[Require __no_such_file]\n"),
+	}
+
+	p := NewParser(nil)
+	err := p.ParseFS(fsys, "__no_such_file")
+	if err == nil {
+		t.Errorf("expected error")
+	}
+
+	err = p.ParseFS(fsys, "main.ini")
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestParseFSLoop(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.ini":     newMapFile("[Require dir/b.ini]\n"),
+		"dir/b.ini": newMapFile("[Require ../a.ini]\n"),
+	}
+
+	p := NewParser(nil)
+	err := p.ParseFS(fsys, "a.ini")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	actual := err.Error()
+	expected := "dir/b.ini:1: include loop"
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestParseFSRepeatOnce(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini":   newMapFile("[Require a.ini]\n[Require b.ini]\n"),
+		"a.ini":      newMapFile("[Require common.ini]\n"),
+		"b.ini":      newMapFile("[Require ./common.ini]\n"),
+		"common.ini": newMapFile("[common]\nL0 += V0\n"),
+	}
+
+	p := NewParser(nil)
+	p.Repeat = RepeatOnce
+	err := p.ParseFS(fsys, "main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "V0"
+	actual := p.Config.Value("common", "L0")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestParseFSIncludePath(t *testing.T) {
+	t.Setenv(IncludePathEnv, "testdata")
+
+	fsys := fstest.MapFS{
+		"vehicle/main.ini":  newMapFile("[Require imc.ini]\n[Include include00.ini]\n"),
+		"library/imc.ini":   newMapFile("[imc]\nL0 = V0\n"),
+		"library/other.ini": newMapFile("[other]\nL0 = V0\n"),
+	}
+
+	p := NewParser(nil)
+	p.IncludePath = []string{"library"}
+	err := p.ParseFS(fsys, "vehicle/main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The host folders in the environment are not searched.
+	expected := map[string]map[string]string{
+		"imc": {"L0": "V0"},
+	}
+
+	actual := p.Config.Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("\nexpected: %q\nactual: %q", expected, actual)
+	}
+
+	res := p.Resolutions()
+	if len(res) != 2 || res[0].Path != "library/imc.ini" {
+		t.Errorf("unexpected resolutions: %+v", res)
+	}
+}
+
+func TestParseFSGlob(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini":               newMapFile("[Require conf.d/**/*.ini]\n[Include none/*.ini]\n"),
+		"conf.d/b.ini":           newMapFile("[glob]\nOrder += b\n"),
+		"conf.d/a.ini":           newMapFile("[glob]\nOrder += a\n"),
+		"conf.d/sub/c.ini":       newMapFile("[glob]\nOrder += c\n"),
+		"conf.d/sub/readme.txt":  newMapFile("text\n"),
+		"conf.d/sub/deep/d.ini":  newMapFile("[glob]\nOrder += d\n"),
+		"conf.d/other/e.ini.bak": newMapFile("[glob]\nOrder += e\n"),
+	}
+
+	p := NewParser(nil)
+	err := p.ParseFS(fsys, "main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "a b c d"
+	actual := p.Config.Value("glob", "Order")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+// deniedFS wraps an fs.FS denying access to a file.
+type deniedFS struct {
+	fstest.MapFS
+	denied string
+}
+
+func (f deniedFS) Open(name string) (fs.File, error) {
+	if name == f.denied {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+
+	return f.MapFS.Open(name)
+}
+
+func TestParseFSIncludeDenied(t *testing.T) {
+	fsys := deniedFS{
+		MapFS: fstest.MapFS{
+			"main.ini":   newMapFile("[Include secret.ini]\n"),
+			"secret.ini": newMapFile("[secret]\nL0 = V0\n"),
+		},
+		denied: "secret.ini",
+	}
+
+	p := NewParser(nil)
+	p.OnWarning = func(w *Warning) {
+		t.Errorf("unexpected warning: %v", w)
+	}
+
+	err := p.ParseFS(fsys, "main.ini")
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected permission error, actual: %v", err)
+	}
+
+	var readErr *ReadError
+	if !errors.As(err, &readErr) {
+		t.Fatalf("expected *ReadError, actual: %v", err)
+	}
+
+	expected := "main.ini:1: read error: open secret.ini: permission denied"
+	if err.Error() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, err.Error())
+	}
+}
+
+func TestParseFSRequireMissing(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini": newMapFile("[main]\nL0 = V0\n[Require missing.ini]\n"),
+	}
+
+	p := NewParser(nil)
+	err := p.ParseFS(fsys, "main.ini")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected: %v, actual: %v", fs.ErrNotExist, err)
+	}
+
+	expected := "main.ini:3: read error: open missing.ini: file does not exist"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected: %q, actual: %v", expected, err)
+	}
+}
+
+func TestParseFSSandbox(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root/main.ini":   newMapFile("[Require sub/a.ini]\n"),
+		"root/sub/a.ini":  newMapFile("[Require /root/common.ini]\n"),
+		"root/common.ini": newMapFile("[common]\nL0 = V0\n"),
+		"root/escape.ini": newMapFile("[Require /other.ini]\n"),
+		"other.ini":       newMapFile("[other]\nL0 = V0\n"),
+	}
+
+	p := NewParser(nil)
+	p.Sandbox.Root = "root"
+	err := p.ParseFS(fsys, "root/main.ini")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = p.ParseFS(fsys, "root/escape.ini")
+
+	var secErr *SecurityError
+	if !errors.As(err, &secErr) {
+		t.Fatalf("expected *SecurityError, actual: %v", err)
+	}
+
+	expected := "root/escape.ini:1: include outside sandbox: /other.ini"
+	if err.Error() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hasMeta tests if path contains any of the glob pattern special characters.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// glob returns the names of all files of file system fsys matching pattern
+// in lexical order. Besides the syntax of filepath.Match, a path element
+// consisting only of "**" matches zero or more directories. Like
+// filepath.Glob, I/O errors are ignored and the only possible error is
+// filepath.ErrBadPattern.
+func glob(fsys fileSystem, pattern string) ([]string, error) {
+	dir, elems := fsys.Split(pattern)
+
+	var matches []string
+	err := globDir(fsys, dir, elems, &matches)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+
+	// Patterns with several "**" elements may match a file more than once.
+	uniq := matches[:0]
+	for i, match := range matches {
+		if i == 0 || match != matches[i-1] {
+			uniq = append(uniq, match)
+		}
+	}
+
+	return uniq, nil
+}
+
+// globDir appends to matches the files under folder dir matching path
+// elements elems.
+func globDir(fsys fileSystem, dir string, elems []string, matches *[]string) error {
+	elem, rest := elems[0], elems[1:]
+
+	if elem == "**" {
+		if len(rest) == 0 {
+			rest = []string{"*"}
+		}
+
+		err := globDir(fsys, dir, rest, matches)
+		if err != nil {
+			return err
+		}
+
+		entries, _ := fsys.ReadDir(dir)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				err = globDir(fsys, fsys.Join(dir, entry.Name()), elems, matches)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if !hasMeta(elem) {
+		path := fsys.Join(dir, elem)
+		if len(rest) > 0 {
+			return globDir(fsys, path, rest, matches)
+		}
+
+		info, err := fsys.Stat(path)
+		if err == nil && !info.IsDir() {
+			*matches = append(*matches, path)
+		}
+
+		return nil
+	}
+
+	// Validate the pattern even if the folder cannot be read.
+	_, err := filepath.Match(elem, "")
+	if err != nil {
+		return err
+	}
+
+	entries, _ := fsys.ReadDir(dir)
+	for _, entry := range entries {
+		matched, _ := filepath.Match(elem, entry.Name())
+		if !matched {
+			continue
+		}
+
+		path := fsys.Join(dir, entry.Name())
+		if len(rest) > 0 {
+			err = globDir(fsys, path, rest, matches)
+			if err != nil {
+				return err
+			}
+		} else if !entry.IsDir() {
+			*matches = append(*matches, path)
+		}
+	}
+
+	return nil
+}
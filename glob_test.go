@@ -0,0 +1,83 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		matches []string
+	}{
+		{"testdata/conf.d/*.ini", []string{
+			"testdata/conf.d/a.ini",
+			"testdata/conf.d/b.ini",
+		}},
+		{"testdata/conf.d/**/*.ini", []string{
+			"testdata/conf.d/a.ini",
+			"testdata/conf.d/b.ini",
+			"testdata/conf.d/sub/c.ini",
+			"testdata/conf.d/sub/deeper/d.ini",
+		}},
+		{"testdata/conf.d/**", []string{
+			"testdata/conf.d/a.ini",
+			"testdata/conf.d/b.ini",
+			"testdata/conf.d/notes.txt",
+			"testdata/conf.d/sub/c.ini",
+			"testdata/conf.d/sub/deeper/d.ini",
+		}},
+		{"testdata/conf.d/**/**/d.ini", []string{
+			"testdata/conf.d/sub/deeper/d.ini",
+		}},
+		{"testdata/conf.d/s?b/c.ini", []string{
+			"testdata/conf.d/sub/c.ini",
+		}},
+		{"testdata/conf.d/*", []string{
+			"testdata/conf.d/a.ini",
+			"testdata/conf.d/b.ini",
+			"testdata/conf.d/notes.txt",
+		}},
+		{"testdata/__no_such_dir/*.ini", nil},
+	}
+
+	for idx, tt := range tests {
+		matches, err := glob(hostFS{}, tt.pattern)
+		if err != nil {
+			t.Errorf("idx: %d, unexpected error: %v", idx, err)
+		}
+
+		if len(matches) == 0 {
+			matches = nil
+		}
+
+		if !reflect.DeepEqual(tt.matches, matches) {
+			t.Errorf("idx: %d, expected: %q, actual: %q", idx, tt.matches, matches)
+		}
+	}
+}
+
+func TestGlobBadPattern(t *testing.T) {
+	_, err := glob(hostFS{}, "testdata/__no_such_dir/[.ini")
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
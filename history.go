@@ -0,0 +1,185 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrRevision is returned when a revision is not in the history.
+var ErrRevision = errors.New("unknown revision")
+
+// Revision is a committed modification of a Config.
+type Revision struct {
+	Number  uint64    // Revision number, see Snapshot.Revision.
+	Time    time.Time // Time of the commit.
+	Changes []Change  // Changes relative to the previous revision.
+	snap    *Snapshot // Contents after the commit.
+}
+
+// Snapshot returns the contents of the configuration at the revision.
+func (rev *Revision) Snapshot() *Snapshot {
+	return rev.snap
+}
+
+// SetHistory enables keeping the last n committed revisions of c, including
+// the current one. A value of zero disables the history and discards the
+// revisions kept.
+func (c *Config) SetHistory(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.historySize = n
+	if n <= 0 {
+		c.history = nil
+		return
+	}
+
+	if len(c.history) == 0 {
+		snap := c.Snapshot()
+		c.history = []*Revision{{Number: snap.revision, Time: time.Now(), snap: snap}}
+	}
+
+	c.trimHistory()
+}
+
+// record adds a revision to the history. Must be called with the writer
+// lock held.
+func (c *Config) record(snap *Snapshot, changes []Change) {
+	c.history = append(c.history, &Revision{
+		Number:  snap.revision,
+		Time:    time.Now(),
+		Changes: changes,
+		snap:    snap,
+	})
+
+	c.trimHistory()
+}
+
+func (c *Config) trimHistory() {
+	excess := len(c.history) - c.historySize
+	if excess > 0 {
+		copy(c.history, c.history[excess:])
+		clear(c.history[len(c.history)-excess:])
+		c.history = c.history[:len(c.history)-excess]
+	}
+}
+
+// Revisions returns the revisions in the history, oldest first.
+func (c *Config) Revisions() []Revision {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	revs := make([]Revision, len(c.history))
+	for i, rev := range c.history {
+		revs[i] = *rev
+	}
+
+	return revs
+}
+
+// revision returns revision number n. Must be called with the writer lock
+// held.
+func (c *Config) revision(n uint64) (*Revision, error) {
+	for _, rev := range c.history {
+		if rev.Number == n {
+			return rev, nil
+		}
+	}
+
+	return nil, fmt.Errorf("revision %d: %w", n, ErrRevision)
+}
+
+// Diff returns the changes from revision from to revision to, sorted by
+// section and label. Both revisions must be in the history.
+func (c *Config) Diff(from uint64, to uint64) ([]Change, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fromRev, err := c.revision(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toRev, err := c.revision(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(fromRev.snap, toRev.snap), nil
+}
+
+// Rollback restores the contents of revision n, which must be in the
+// history. The rollback is committed as a new revision, whose changes have
+// the source "rollback to revision N". The values restored keep their
+// original sources, see Config.Source.
+func (c *Config) Rollback(n uint64) error {
+	return c.Update(func(tx *Tx) error {
+		rev, err := c.revision(n)
+		if err != nil {
+			return err
+		}
+
+		tx.SetSource(fmt.Sprintf("rollback to revision %d", n))
+		tx.do(func(tx *Tx) {
+			tx.restore(rev.snap)
+		})
+
+		return nil
+	})
+}
+
+// diff returns the changes from snapshot a to snapshot b, sorted by section
+// and label.
+func diff(a *Snapshot, b *Snapshot) []Change {
+	var changes []Change
+
+	for s, section := range a.cfg {
+		for l, oldValue := range section {
+			newValue, exists := b.lookup(s, l)
+			if !exists {
+				changes = append(changes, Change{ValueRemoved, s, l, oldValue, "", ""})
+			} else if newValue != oldValue {
+				changes = append(changes, Change{ValueModified, s, l, oldValue, newValue, ""})
+			}
+		}
+	}
+
+	for s, section := range b.cfg {
+		for l, newValue := range section {
+			_, exists := a.lookup(s, l)
+			if !exists {
+				changes = append(changes, Change{ValueAdded, s, l, "", newValue, ""})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+
+		return changes[i].Label < changes[j].Label
+	})
+
+	return changes
+}
@@ -0,0 +1,190 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("Gains", "Kp", "1")
+	c.SetHistory(3)
+
+	c.Update(func(tx *Tx) error {
+		tx.SetSource("tuner")
+		tx.SetValue("Gains", "Kp", "2")
+		tx.SetValue("Gains", "Ki", "3")
+		return nil
+	})
+	c.SetValue("Gains", "Kp", "4")
+
+	revs := c.Revisions()
+	var numbers []uint64
+	for _, rev := range revs {
+		numbers = append(numbers, rev.Number)
+	}
+
+	expectedNumbers := []uint64{1, 2, 3}
+	if !reflect.DeepEqual(expectedNumbers, numbers) {
+		t.Fatalf("expected: %v, actual: %v", expectedNumbers, numbers)
+	}
+
+	if c.Snapshot().Revision() != 3 {
+		t.Errorf("expected: %d, actual: %d", 3, c.Snapshot().Revision())
+	}
+
+	expectedChanges := []Change{
+		{ValueModified, "Gains", "Kp", "1", "2", "tuner"},
+		{ValueAdded, "Gains", "Ki", "", "3", "tuner"},
+	}
+
+	if !reflect.DeepEqual(expectedChanges, revs[1].Changes) {
+		t.Errorf("expected: %v, actual: %v", expectedChanges, revs[1].Changes)
+	}
+
+	if revs[0].Snapshot().Value("Gains", "Kp") != "1" || revs[1].Time.Before(revs[0].Time) {
+		t.Errorf("unexpected revision: %v", revs[0])
+	}
+
+	// Oldest revision discarded.
+	c.SetValue("Gains", "Kd", "5")
+	revs = c.Revisions()
+	if len(revs) != 3 || revs[0].Number != 2 {
+		t.Errorf("unexpected revisions: %v", revs)
+	}
+
+	c.SetHistory(0)
+	if len(c.Revisions()) != 0 {
+		t.Errorf("history not discarded")
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	c := NewConfig()
+	c.SetHistory(10)
+	c.SetMap(map[string]map[string]string{
+		"S0": {"L0": "V0", "L1": "V1"},
+		"S1": {"L0": "V0"},
+	})
+	c.Update(func(tx *Tx) error {
+		tx.SetValue("S0", "L0", "V2")
+		tx.RemoveSection("S1")
+		tx.SetValue("S2", "L0", "V0")
+		return nil
+	})
+
+	expected := []Change{
+		{ValueModified, "S0", "L0", "V0", "V2", ""},
+		{ValueRemoved, "S1", "L0", "V0", "", ""},
+		{ValueAdded, "S2", "L0", "", "V0", ""},
+	}
+
+	actual, err := c.Diff(1, 2)
+	if err != nil || !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v, %v", expected, actual, err)
+	}
+
+	actual, err = c.Diff(0, 0)
+	if err != nil || len(actual) != 0 {
+		t.Errorf("expected no changes, actual: %v, %v", actual, err)
+	}
+
+	_, err = c.Diff(0, 3)
+	if !errors.Is(err, ErrRevision) {
+		t.Errorf("expected: %v, actual: %v", ErrRevision, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	c := NewConfig()
+	c.SetHistory(10)
+	c.SetMap(map[string]map[string]string{"Gains": {"Kp": "1", "Ki": "2"}})
+	good := c.Snapshot().Revision()
+
+	c.Update(func(tx *Tx) error {
+		tx.SetValue("Gains", "Kp", "100")
+		tx.Remove("Gains", "Ki")
+		return nil
+	})
+
+	ch := make(chan []Change, 1)
+	sub := c.Subscribe(Filter{}, func(changes []Change) {
+		ch <- changes
+	})
+	defer sub.Unsubscribe()
+
+	err := c.Rollback(good)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"Gains": {"Kp": "1", "Ki": "2"}}
+	if !reflect.DeepEqual(expected, c.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, c.Map())
+	}
+
+	if c.Snapshot().Revision() != 3 || len(c.Revisions()) != 4 {
+		t.Errorf("rollback not committed as a new revision")
+	}
+
+	changes := receiveWithin(t, ch)
+	if len(changes) != 2 || changes[0].Source != "rollback to revision 1" {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+
+	err = c.Rollback(10)
+	if !errors.Is(err, ErrRevision) || c.Snapshot().Revision() != 3 {
+		t.Errorf("expected: %v, actual: %v", ErrRevision, err)
+	}
+}
+
+func TestRollbackSources(t *testing.T) {
+	c := NewConfig()
+	c.SetHistory(10)
+	c.Update(func(tx *Tx) error {
+		tx.SetSource("defaults")
+		tx.SetMap(map[string]map[string]string{"Gains": {"Kp": "1", "Ki": "2"}})
+		return nil
+	})
+	good := c.Snapshot().Revision()
+
+	c.Update(func(tx *Tx) error {
+		tx.SetSource("tuner")
+		tx.SetValue("Gains", "Kp", "100")
+		return nil
+	})
+
+	err := c.Rollback(good)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.Source("Gains", "Kp") != "defaults" {
+		t.Errorf("expected: %q, actual: %q", "defaults", c.Source("Gains", "Kp"))
+	}
+
+	revs := c.Revisions()
+	changes := revs[len(revs)-1].Changes
+	if len(changes) != 1 || changes[0].Source != "rollback to revision 1" {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+}
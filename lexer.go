@@ -0,0 +1,217 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the kind of a line.
+type tokenKind int
+
+const (
+	// tokenEmpty is a blank line or a line with only a comment.
+	tokenEmpty tokenKind = iota
+	// tokenSection is a section name or a directive in square brackets.
+	tokenSection
+	// tokenAppend is an append instruction, i.e., "label += value".
+	tokenAppend
+	// tokenAssign is an assignment instruction, i.e., "label = value".
+	tokenAssign
+	// tokenValue is a line continuing a multi-line value.
+	tokenValue
+)
+
+// token is the result of scanning a line. Columns are 1-based byte offsets
+// in the line, zero when not applicable.
+type token struct {
+	kind       tokenKind // Kind of line.
+	name       string    // Section name or label.
+	value      string    // Value of append and assign instructions.
+	text       string    // Line without comments and surrounding white space.
+	comment    string    // Comment text, without markers.
+	nameCol    int       // Column of name.
+	valueCol   int       // Column of value.
+	commentCol int       // Column of the comment markers.
+}
+
+// isCommentMarker tests if c starts a comment.
+func isCommentMarker(c byte) bool {
+	return c == ';' || c == '|' || c == '#'
+}
+
+// trimLeftSpace returns s without leading white space.
+func trimLeftSpace(s string) string {
+	return strings.TrimLeftFunc(s, unicode.IsSpace)
+}
+
+// trimSpan returns the bounds of s[lo:hi] without leading and trailing white
+// space.
+func trimSpan(s string, lo int, hi int) (int, int) {
+	trimmed := trimLeftSpace(s[lo:hi])
+	lo = hi - len(trimmed)
+	hi = lo + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+	return lo, hi
+}
+
+// findComment returns the index of the first comment marker of line, or the
+// length of line if there is none.
+func findComment(line string) int {
+	for i := 0; i < len(line); i++ {
+		if isCommentMarker(line[i]) {
+			return i
+		}
+	}
+
+	return len(line)
+}
+
+// findSection returns the bounds of the name of the leftmost section of
+// line, i.e., the text between an opening square bracket and the next
+// closing square bracket. The name cannot be empty.
+func findSection(line string) (int, int, bool) {
+	for i := 0; i+1 < len(line); i++ {
+		if line[i] != '[' || line[i+1] == ']' {
+			continue
+		}
+
+		end := strings.IndexByte(line[i+2:], ']')
+		if end < 0 {
+			return 0, 0, false
+		}
+
+		return i + 1, i + 2 + end, true
+	}
+
+	return 0, 0, false
+}
+
+// findOperator returns the index of the leftmost operator op of line that is
+// preceded by at least one character since the previous occurrence of the
+// first character of op, along with the index following that occurrence.
+func findOperator(line string, op string) (int, int, bool) {
+	prev := -1
+	for i := 0; i < len(line); i++ {
+		if line[i] != op[0] {
+			continue
+		}
+
+		if i > prev+1 && strings.HasPrefix(line[i:], op) {
+			return prev + 1, i, true
+		}
+
+		prev = i
+	}
+
+	return 0, 0, false
+}
+
+// scanLine splits line in its components.
+func scanLine(line string) token {
+	var tok token
+
+	end := findComment(line)
+	if end < len(line) {
+		lo := end
+		for lo < len(line) && isCommentMarker(line[lo]) {
+			lo++
+		}
+
+		lo, hi := trimSpan(line, lo, len(line))
+		tok.comment = line[lo:hi]
+		tok.commentCol = end + 1
+	}
+
+	lo, hi := trimSpan(line, 0, end)
+	text := line[lo:hi]
+	tok.text = text
+	if text == "" {
+		return tok
+	}
+
+	if nameLo, nameHi, ok := findSection(text); ok {
+		tok.kind = tokenSection
+		tok.setName(line, lo+nameLo, lo+nameHi)
+		return tok
+	}
+
+	if start, i, ok := findOperator(text, "+="); ok {
+		tok.kind = tokenAppend
+		tok.setName(line, lo+start, lo+i)
+		tok.setValue(line, lo+i+2, hi)
+		return tok
+	}
+
+	if start, i, ok := findOperator(text, "="); ok {
+		tok.kind = tokenAssign
+		tok.setName(line, lo+start, lo+i)
+		tok.setValue(line, lo+i+1, hi)
+		return tok
+	}
+
+	tok.kind = tokenValue
+	return tok
+}
+
+// setName sets the name of the token to line[lo:hi] without surrounding
+// white space.
+func (tok *token) setName(line string, lo int, hi int) {
+	lo, hi = trimSpan(line, lo, hi)
+	tok.name = line[lo:hi]
+	tok.nameCol = lo + 1
+}
+
+// setValue sets the value of the token to line[lo:hi] without surrounding
+// white space.
+func (tok *token) setValue(line string, lo int, hi int) {
+	lo, hi = trimSpan(line, lo, hi)
+	tok.value = line[lo:hi]
+	tok.valueCol = lo + 1
+}
+
+func removeComments(line string) string {
+	return scanLine(line).text
+}
+
+func readSectionName(line string) (bool, string) {
+	lo, hi, ok := findSection(line)
+	if !ok {
+		return false, ""
+	}
+
+	return true, strings.TrimSpace(line[lo:hi])
+}
+
+func readLabelValue(line string, op string) (bool, string, string) {
+	start, i, ok := findOperator(line, op)
+	if !ok {
+		return false, "", ""
+	}
+
+	return true, strings.TrimSpace(line[start:i]), strings.TrimSpace(line[i+len(op):])
+}
+
+func readAppend(line string) (bool, string, string) {
+	return readLabelValue(line, "+=")
+}
+
+func readAssign(line string) (bool, string, string) {
+	return readLabelValue(line, "=")
+}
@@ -0,0 +1,207 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Regular expressions of the original line classifier, used as reference.
+var (
+	refComment = regexp.MustCompile(`[;|#]+(.*)$`)
+	refSection = regexp.MustCompile(`\[([^]]+)]`)
+	refAppend  = regexp.MustCompile(`([^+]+)\+=(.*)`)
+	refAssign  = regexp.MustCompile(`([^=]+)=(.*)`)
+)
+
+// refScanLine classifies line using regular expressions.
+func refScanLine(line string) token {
+	var tok token
+
+	tok.text = strings.TrimSpace(refComment.ReplaceAllString(strings.TrimSpace(line), ""))
+	if tok.text == "" {
+		return tok
+	}
+
+	if m := refSection.FindStringSubmatch(tok.text); m != nil {
+		tok.kind = tokenSection
+		tok.name = strings.TrimSpace(m[1])
+		return tok
+	}
+
+	if m := refAppend.FindStringSubmatch(tok.text); m != nil {
+		tok.kind = tokenAppend
+		tok.name = strings.TrimSpace(m[1])
+		tok.value = strings.TrimSpace(m[2])
+		return tok
+	}
+
+	if m := refAssign.FindStringSubmatch(tok.text); m != nil {
+		tok.kind = tokenAssign
+		tok.name = strings.TrimSpace(m[1])
+		tok.value = strings.TrimSpace(m[2])
+		return tok
+	}
+
+	tok.kind = tokenValue
+	return tok
+}
+
+// checkScanLine compares scanLine with the regular expression classifier.
+func checkScanLine(t *testing.T, line string) {
+	expected := refScanLine(line)
+	actual := scanLine(line)
+
+	if expected.kind != actual.kind || expected.name != actual.name ||
+		expected.value != actual.value || expected.text != actual.text {
+		t.Errorf("line: %q\nexpected: %d %q %q %q\nactual: %d %q %q %q", line,
+			expected.kind, expected.name, expected.value, expected.text,
+			actual.kind, actual.name, actual.value, actual.text)
+	}
+}
+
+var scanLineCorpus = []string{
+	"",
+	"   ",
+	"; comment",
+	"# comment",
+	"| comment",
+	"########",
+	"[Section]",
+	"  [ Section A ]  # comment",
+	"[]",
+	"[ ]",
+	"[]]",
+	"[[]",
+	"[][x]",
+	"[x",
+	"x]",
+	"a [b] c",
+	"Label = [Value]",
+	"[Require file.ini]",
+	"[Include conf.d/*.ini]",
+	"Label = Value",
+	"Label=Value",
+	"Label =",
+	"= Value",
+	"==a=b",
+	"Label += Value",
+	"Label+=Value",
+	"+= Value",
+	"a+b+=c",
+	"a++=b",
+	"+a+=b",
+	"Label = a += b",
+	"Label += a = b",
+	"Label 1 = Value ; comment",
+	"Value continuation",
+	"a=b\r",
+	"\tLabel\t=\tValue\t",
+	"Label =  Value ",
+	"Label = \xff\xfe",
+}
+
+func TestScanLineDifferential(t *testing.T) {
+	for _, line := range scanLineCorpus {
+		checkScanLine(t, line)
+	}
+
+	const alphabet = "[]=+#;| \tab\xff"
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		b := make([]byte, rnd.Intn(12))
+		for j := range b {
+			b[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+
+		checkScanLine(t, string(b))
+	}
+}
+
+func FuzzScanLine(f *testing.F) {
+	for _, line := range scanLineCorpus {
+		f.Add(line)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		if strings.Contains(line, "\n") {
+			t.Skip()
+		}
+
+		checkScanLine(t, line)
+	})
+}
+
+func TestScanLinePositions(t *testing.T) {
+	var tests = []struct {
+		line       string
+		kind       tokenKind
+		nameCol    int
+		valueCol   int
+		commentCol int
+		comment    string
+	}{
+		{"  Label = Value ; comment", tokenAssign, 3, 11, 17, "comment"},
+		{"Label+=Value", tokenAppend, 1, 8, 0, ""},
+		{"  [ Section ] ##  text  ", tokenSection, 5, 0, 15, "text"},
+		{"# only", tokenEmpty, 0, 0, 1, "only"},
+	}
+
+	for idx, tt := range tests {
+		tok := scanLine(tt.line)
+		if tok.kind != tt.kind || tok.nameCol != tt.nameCol || tok.valueCol != tt.valueCol ||
+			tok.commentCol != tt.commentCol || tok.comment != tt.comment {
+			t.Errorf("idx: %d, expected: %d %d %d %d %q, actual: %d %d %d %d %q", idx,
+				tt.kind, tt.nameCol, tt.valueCol, tt.commentCol, tt.comment,
+				tok.kind, tok.nameCol, tok.valueCol, tok.commentCol, tok.comment)
+		}
+	}
+}
+
+var benchLines = []string{
+	"# Vehicle configuration",
+	"[Navigation/AUV/Navigation]",
+	"Enabled                                 = Always",
+	"Entity Label                            = Navigation",
+	"Maximum Speed                           += 1.5, 2.0",
+	"  Continuation of a value",
+	"",
+}
+
+func BenchmarkScanLine(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range benchLines {
+			scanLine(line)
+		}
+	}
+}
+
+func BenchmarkScanLineRegexp(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range benchLines {
+			refScanLine(line)
+		}
+	}
+}
@@ -21,41 +21,117 @@
 package ini
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 )
 
-// Compiled regular expressions.
-var (
-	// Match comments.
-	reComment = regexp.MustCompile(`[;|#]+(.*)$`)
-	// Match sections.
-	reSection = regexp.MustCompile(`\[([^]]+)]`)
-	// Match append instructions.
-	reAppend = regexp.MustCompile(`([^+]+)\+=(.*)`)
-	// Match assignment instructions.
-	reAssign = regexp.MustCompile(`([^=]+)=(.*)`)
+// RepeatPolicy selects how files included more than once are handled.
+type RepeatPolicy int
+
+const (
+	// RepeatAllow parses a file every time it is included.
+	RepeatAllow RepeatPolicy = iota
+	// RepeatOnce parses a file only the first time it is included.
+	RepeatOnce
+	// RepeatError rejects files included more than once.
+	RepeatError
 )
 
-// Parser is an INI format parser.
+// IncludePathEnv is the name of the environment variable holding a list of
+// directories searched for include files after those in Parser.IncludePath.
+// Directories are separated by the OS-specific path list separator.
+const IncludePathEnv = "INI_INCLUDE_PATH"
+
+// Resolution records how the target of an Include or Require directive was
+// resolved.
+type Resolution struct {
+	File       string   // File containing the directive.
+	LineNr     uint     // Line number of the directive.
+	Target     string   // Target as written in the directive.
+	Candidates []string // Candidate paths in search order.
+	Path       string   // Chosen candidate, empty if none was found.
+	Matches    []string // Files matching Path if Target is a glob pattern.
+}
+
+// Sandbox confines the files included by a parse to a root folder. The file
+// given to ParseFile or ParseFS is not subject to the sandbox.
+type Sandbox struct {
+	Root     string // Root folder, empty to disable the sandbox.
+	Symlinks bool   // Reject symbolic links leading outside Root.
+}
+
+// Limits bounds the resources consumed by a parse. A zero value means no
+// limit.
+type Limits struct {
+	MaxLineLength   int   // Maximum length of a line in bytes.
+	MaxBytes        int64 // Maximum number of bytes read from all files.
+	MaxIncludeDepth int   // Maximum nesting depth of included files.
+	MaxFiles        int   // Maximum number of files parsed.
+	MaxSections     int   // Maximum number of distinct sections.
+	MaxLabels       int   // Maximum number of distinct labels per section.
+}
+
+// Parser is an INI format parser. The values parsed are published to Config
+// at once when a parse ends, even if it fails, as a single revision: while a
+// parse runs, readers and subscribers of Config do not observe any of its
+// values. Modifications made to Config by others during a parse are kept,
+// unless the parse assigns the same labels.
+//
+// Lines between the directives [If Condition] and [EndIf], with optional
+// [ElseIf Condition] and [Else] directives in between, are parsed only in the
+// first branch whose condition holds. Conditions compare Variables, e.g.,
+// "Profile == Simulation" or "Profile != Hardware", or test if a variable is
+// defined, e.g., "Simulation". Conditional blocks may be nested but must be
+// closed in the file where they are opened.
+//
+// Directives other than Require, Include and those of conditional blocks can
+// be added with RegisterDirective, or RegisterStandardDirectives for Define,
+// Error and Warning.
 type Parser struct {
-	Config       *Config         // Configuration instance.
-	curSection   string          // Section being parsed.
-	curLabel     string          // Label being parsed.
-	fileStack    []string        // File stack, top is file being parsed.
-	lineNrStack  []uint          // Line number stack.
-	visitedFiles map[string]bool // Set of visited files.
+	Config       *Config                    // Configuration instance.
+	Repeat       RepeatPolicy               // Policy for files included more than once.
+	IncludePath  []string                   // Directories searched for include files.
+	Logger       *slog.Logger               // Diagnostics logger, nil to disable.
+	OnWarning    func(*Warning)             // Function called for each warning.
+	Limits       Limits                     // Resource limits.
+	Sandbox      Sandbox                    // Confinement of included files.
+	Prefetch     int                        // Number of files read concurrently, zero to disable.
+	Variables    map[string]string          // Variables of conditional blocks.
+	directives   map[string]DirectiveFunc   // Registered directives.
+	defines      map[string]string          // Variables defined by the parse.
+	recorder     *recorder                  // Recorder of the files read, may be nil.
+	curSection   string                     // Section being parsed.
+	curLabel     string                     // Label being parsed.
+	fileStack    []string                   // File stack, top is file being parsed.
+	lineNrStack  []uint                     // Line number stack.
+	visitedFiles map[string]bool            // Set of visited files.
+	resolutions  []Resolution               // Resolved include files.
+	fs           fileSystem                 // File system of the files being parsed.
+	ctx          context.Context            // Context of the parse.
+	nrBytes      int64                      // Number of bytes read.
+	nrFiles      int                        // Number of files parsed.
+	labels       map[string]map[string]bool // Set of labels of each section.
+	prefetch     *prefetcher                // Reader of files ahead of the parser.
+	tx           *Tx                        // Modifications of Config by the parse.
+	files        []string                   // Files parsed, in order.
+	missing      []string                   // Optional include files not found.
+	conds        []condBlock                // Conditional blocks being parsed.
+	condBases    []int                      // Conditional blocks opened before each file.
 }
 
 // NewParser creates a new instance of Parser.
 func NewParser(c *Config) *Parser {
 	p := new(Parser)
 	p.visitedFiles = make(map[string]bool)
+	p.fs = hostFS{}
+	p.ctx = context.Background()
 	if c == nil {
 		p.Config = NewConfig()
 	} else {
@@ -65,23 +141,132 @@ func NewParser(c *Config) *Parser {
 	return p
 }
 
-// Parse parses an INI format stream.
+// discardLogger is used when no diagnostics logger is configured.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// logger returns the diagnostics logger.
+func (p *Parser) logger() *slog.Logger {
+	if p.Logger == nil {
+		return discardLogger
+	}
+
+	return p.Logger
+}
+
+// warn reports a warning at the current line.
+func (p *Parser) warn(msg string) {
+	if p.OnWarning != nil {
+		p.OnWarning(&Warning{p.curFile(), p.curLineNr(), msg})
+	}
+}
+
+// Resolutions returns how the targets of Include and Require directives
+// were resolved during the last parse, in the order they were found.
+func (p *Parser) Resolutions() []Resolution {
+	return append([]Resolution(nil), p.resolutions...)
+}
+
+// Files returns the paths of the files parsed during the last parse,
+// including the files included by Require and Include directives, in the
+// order they were first opened.
+func (p *Parser) Files() []string {
+	return append([]string(nil), p.files...)
+}
+
+// Parse parses an INI format stream. Include and Require directives are
+// resolved relative to the current working directory.
 func (p *Parser) Parse(reader io.Reader) error {
+	return p.ParseContext(context.Background(), reader)
+}
+
+// ParseContext is like Parse but stops with the context error when ctx is
+// done. The context is checked between lines.
+func (p *Parser) ParseContext(ctx context.Context, reader io.Reader) error {
+	p.ctx = ctx
+	p.fs = hostFS{}
 	return p.parseReader(reader, "")
 }
 
 // ParseFile parses an INI format file.
 func (p *Parser) ParseFile(path string) error {
-	file, err := os.Open(path)
+	return p.ParseFileContext(context.Background(), path)
+}
+
+// ParseFileContext is like ParseFile but stops with the context error when
+// ctx is done. The context is checked between lines.
+func (p *Parser) ParseFileContext(ctx context.Context, path string) error {
+	p.ctx = ctx
+	p.fs = hostFS{}
+	return p.parseFile(path)
+}
+
+// ParseFS parses the INI format file path of file system fsys. All Include
+// and Require directives are resolved within fsys, using slash-separated
+// paths as described in io/fs. Absolute paths are taken relative to the root
+// of fsys. The folders in IncludePath are folders of fsys and the
+// environment variable IncludePathEnv is ignored.
+func (p *Parser) ParseFS(fsys fs.FS, path string) error {
+	return p.ParseFSContext(context.Background(), fsys, path)
+}
+
+// ParseFSContext is like ParseFS but stops with the context error when ctx
+// is done. The context is checked between lines.
+func (p *Parser) ParseFSContext(ctx context.Context, fsys fs.FS, path string) error {
+	p.ctx = ctx
+	p.fs = ioFS{fsys}
+	return p.parseFile(path)
+}
+
+func (p *Parser) parseFile(path string) error {
+	if p.Prefetch > 0 && len(p.fileStack) == 0 {
+		p.startPrefetch()
+		defer p.stopPrefetch()
+	}
+
+	reader, err := p.openFile(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer reader.Close()
 
-	return p.parseReader(file, path)
+	return p.readLines(reader, path, p.handleLine)
 }
 
 func (p *Parser) parseReader(reader io.Reader, path string) error {
+	return p.readLines(p.newStreamReader(reader, nil), path, p.handleLine)
+}
+
+// openFile opens file path for reading, using the prefetched contents if
+// available.
+func (p *Parser) openFile(path string) (lineReader, error) {
+	if p.prefetch != nil {
+		reader, err := p.prefetch.open(path)
+		if reader != nil || err != nil {
+			return reader, err
+		}
+	}
+
+	file, err := p.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.newStreamReader(file, file), nil
+}
+
+// open opens file path of the file system of the parse.
+func (p *Parser) open(path string) (fs.File, error) {
+	file, err := p.fs.Open(path)
+	if p.recorder != nil {
+		return p.recorder.open(path, file, err)
+	}
+
+	return file, err
+}
+
+// readLines reads the lines of file path from reader and calls handler for
+// each of them.
+func (p *Parser) readLines(reader lineReader, path string, handler func(string, token) error) error {
 	err := p.pushFile(path)
 	if err != nil {
 		return err
@@ -89,46 +274,126 @@ func (p *Parser) parseReader(reader io.Reader, path string) error {
 
 	defer p.popFile()
 
-	bio := bufio.NewReader(reader)
-
 	eof := false
 	for !eof {
-		line, err := bio.ReadString('\n')
+		err := p.ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		res := reader.next()
+		line, err := res.line, res.err
 		if err != nil {
-			if err == io.EOF {
-				eof = true
+			if err == ErrLineLength || err == ErrBytes {
+				return &LimitError{p.curFile(), p.curLineNr() + 1, err}
+			}
+
+			if err != io.EOF {
+				return &ReadError{p.curFile(), p.curLineNr() + 1, err}
 			}
+			eof = true
+		}
+
+		p.nrBytes += int64(len(line))
+		if p.Limits.MaxBytes > 0 && p.nrBytes > p.Limits.MaxBytes {
+			return &LimitError{p.curFile(), p.curLineNr() + 1, ErrBytes}
 		}
 
-		err = p.handleLine(line)
+		p.incLineNr()
+		err = handler(line, res.tok)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return p.checkConds()
+}
+
+// isParsing tests if file path is currently on the file stack.
+func (p *Parser) isParsing(path string) bool {
+	key := p.fs.Key(path)
+	for _, file := range p.fileStack {
+		if file != "" && p.fs.Key(file) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isVisited tests if file path was already parsed.
+func (p *Parser) isVisited(path string) bool {
+	return p.visitedFiles[p.fs.Key(path)]
 }
 
 func (p *Parser) pushFile(path string) error {
-	absPath, _ := filepath.Abs(path)
+	if len(p.fileStack) == 0 {
+		p.visitedFiles = make(map[string]bool)
+		p.resolutions = nil
+		p.files = nil
+		p.missing = nil
+		p.nrBytes = 0
+		p.nrFiles = 0
+		p.labels = make(map[string]map[string]bool)
+		if p.curSection != "" {
+			p.labels[p.curSection] = make(map[string]bool)
+		}
+
+		p.conds = nil
+		p.condBases = nil
+		p.defines = nil
+		p.tx = p.Config.begin()
+	} else {
+		err := p.ctx.Err()
+		if err != nil {
+			return err
+		}
 
-	_, exists := p.visitedFiles[absPath]
-	if exists {
-		return &SyntaxError{p.curFile(), p.curLineNr(), "include loop"}
+		if p.Limits.MaxIncludeDepth > 0 && len(p.fileStack) > p.Limits.MaxIncludeDepth {
+			return &LimitError{p.curFile(), p.curLineNr(), ErrIncludeDepth}
+		}
+
+		if p.Limits.MaxFiles > 0 && p.nrFiles >= p.Limits.MaxFiles {
+			return &LimitError{p.curFile(), p.curLineNr(), ErrFiles}
+		}
 	}
 
-	log.Printf("parsing %v\n", path)
-	p.visitedFiles[absPath] = true
+	if path != "" {
+		if p.isParsing(path) {
+			return &SyntaxError{p.curFile(), p.curLineNr(), "include loop"}
+		}
+
+		if p.Repeat == RepeatError && p.isVisited(path) {
+			return &SyntaxError{p.curFile(), p.curLineNr(), "repeated include of " + path}
+		}
+
+		if !p.isVisited(path) {
+			p.files = append(p.files, path)
+		}
+
+		p.visitedFiles[p.fs.Key(path)] = true
+	}
+
+	p.logger().Debug("parsing file", "path", path, "depth", len(p.fileStack))
+	p.nrFiles++
 	p.fileStack = append(p.fileStack, path)
 	p.lineNrStack = append(p.lineNrStack, 0)
+	p.condBases = append(p.condBases, len(p.conds))
 	return nil
 }
 
 func (p *Parser) popFile() {
 	stackLen := len(p.fileStack)
 	if stackLen > 0 {
+		p.conds = p.conds[:p.condBases[stackLen-1]]
 		p.fileStack = p.fileStack[:stackLen-1]
 		p.lineNrStack = p.lineNrStack[:stackLen-1]
+		p.condBases = p.condBases[:stackLen-1]
+	}
+
+	if stackLen == 1 {
+		p.Config.commit(p.tx)
+		p.tx = nil
 	}
 }
 
@@ -144,74 +409,292 @@ func (p *Parser) curLineNr() uint {
 	return p.lineNrStack[len(p.lineNrStack)-1]
 }
 
-func removeComments(line string) string {
-	return strings.TrimSpace(reComment.ReplaceAllString(line, ""))
+// curSource returns the current position as "path:line", or "line" for
+// streams.
+func (p *Parser) curSource() string {
+	lineNr := strconv.FormatUint(uint64(p.curLineNr()), 10)
+	if p.curFile() == "" {
+		return lineNr
+	}
+
+	return p.curFile() + ":" + lineNr
 }
 
-func readSectionName(line string) (bool, string) {
-	matches := reSection.FindStringSubmatch(line)
-	if len(matches) == 2 {
-		return true, strings.TrimSpace(matches[1])
+func (p *Parser) insertValue(section string, label string, value string, append bool) error {
+	if section == "" {
+		return &SyntaxError{p.curFile(), p.curLineNr(), "empty section name"}
+	}
+
+	if label == "" {
+		return &SyntaxError{p.curFile(), p.curLineNr(), "empty label"}
+	}
+
+	if !p.labels[section][label] {
+		if p.Limits.MaxLabels > 0 && len(p.labels[section]) >= p.Limits.MaxLabels {
+			return &LimitError{p.curFile(), p.curLineNr(), ErrLabels}
+		}
+
+		p.labels[section][label] = true
+	}
+
+	p.tx.SetSource(p.curSource())
+
+	if append {
+		p.tx.AppendValue(section, label, value, " ")
+
+		return nil
+	}
+
+	if p.logger().Enabled(context.Background(), slog.LevelInfo) {
+		oldValue, exists := p.tx.lookup(section, label)
+		if exists && oldValue != value {
+			p.logger().Info("value overridden", "file", p.curFile(), "line", p.curLineNr(),
+				"section", section, "label", label, "old", oldValue, "new", value)
+		}
 	}
 
-	return false, ""
+	p.tx.SetValue(section, label, value)
+
+	return nil
 }
 
-func readLabelValue(re *regexp.Regexp, line string) (bool, string, string) {
-	matches := re.FindStringSubmatch(line)
-	if len(matches) == 3 {
-		return true, strings.TrimSpace(matches[1]), strings.TrimSpace(matches[2])
+// includeDirs returns the directories searched for files included by file:
+// the folder of file, followed by the folders in IncludePath and in the
+// environment variable IncludePathEnv.
+func (p *Parser) includeDirs(file string) []string {
+	dirs := []string{p.fs.Dir(file)}
+	dirs = append(dirs, p.IncludePath...)
+
+	if _, ok := p.fs.(hostFS); !ok {
+		return dirs
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv(IncludePathEnv)) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
 	}
 
-	return false, "", ""
+	return dirs
 }
 
-func readAppend(line string) (bool, string, string) {
-	return readLabelValue(reAppend, line)
+// includeCandidates returns the candidate paths of target included by file,
+// in search order. Candidates outside the sandbox are discarded.
+func (p *Parser) includeCandidates(file string, target string) []string {
+	var candidates []string
+	if p.fs.IsAbs(target) {
+		candidates = []string{p.fs.Join(target)}
+	} else {
+		for _, dir := range p.includeDirs(file) {
+			candidates = append(candidates, p.fs.Join(dir, target))
+		}
+	}
+
+	if p.Sandbox.Root == "" {
+		return candidates
+	}
+
+	var confined []string
+	for _, candidate := range candidates {
+		if p.fs.Within(p.Sandbox.Root, candidate) {
+			confined = append(confined, candidate)
+		}
+	}
+
+	return confined
 }
 
-func readAssign(line string) (bool, string, string) {
-	return readLabelValue(reAssign, line)
+// curIncludeCandidates returns the candidate paths of target included by the
+// file being parsed.
+func (p *Parser) curIncludeCandidates(target string) ([]string, error) {
+	candidates := p.includeCandidates(p.curFile(), target)
+	if len(candidates) == 0 {
+		return nil, &SecurityError{p.curFile(), p.curLineNr(), "include outside sandbox: " + target}
+	}
+
+	return candidates, nil
 }
 
-func (p *Parser) insertValue(section string, label string, value string, append bool) error {
-	if section == "" {
-		return &SyntaxError{p.curFile(), p.curLineNr(), "empty section name"}
+// escapesSandbox tests if file path, after following symbolic links, is
+// outside the sandbox. The test is performed only if enabled by
+// Sandbox.Symlinks.
+func (p *Parser) escapesSandbox(path string) bool {
+	if p.Sandbox.Root == "" || !p.Sandbox.Symlinks {
+		return false
 	}
 
-	if label == "" {
-		return &SyntaxError{p.curFile(), p.curLineNr(), "empty label"}
+	realPath, err := p.fs.EvalSymlinks(path)
+	if err != nil {
+		// Missing files are handled when opened.
+		return false
 	}
 
-	if append {
-		p.Config.AppendValue(section, label, value, " ")
-	} else {
-		p.Config.SetValue(section, label, value)
+	realRoot, err := p.fs.EvalSymlinks(p.Sandbox.Root)
+	if err != nil {
+		realRoot = p.Sandbox.Root
+	}
+
+	return !p.fs.Within(realRoot, realPath)
+}
+
+func (p *Parser) checkSymlinks(path string) error {
+	if p.escapesSandbox(path) {
+		return &SecurityError{p.curFile(), p.curLineNr(), "symbolic link outside sandbox: " + path}
 	}
 
 	return nil
 }
 
-func (p *Parser) resolveIncludeFile(path string) string {
-	curFolder := filepath.Dir(p.curFile())
-	return filepath.Join(curFolder, strings.TrimSpace(path))
+// findFile returns the first of candidates that exists, or an empty string
+// if none exists.
+func (p *Parser) findFile(candidates []string) string {
+	for _, candidate := range candidates {
+		_, err := p.fs.Stat(candidate)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// findPattern returns the first of the glob pattern candidates with at least
+// one match, along with its matches.
+func (p *Parser) findPattern(candidates []string) (string, []string, error) {
+	for _, candidate := range candidates {
+		matches, err := glob(p.fs, candidate)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if len(matches) > 0 {
+			return candidate, matches, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// resolveIncludeFile searches the include directories for file target and
+// returns the first candidate that exists. If none exists the first
+// candidate is returned.
+func (p *Parser) resolveIncludeFile(target string) (string, error) {
+	candidates, err := p.curIncludeCandidates(target)
+	if err != nil {
+		return "", err
+	}
+
+	res := Resolution{File: p.curFile(), LineNr: p.curLineNr(), Target: target}
+	res.Candidates = candidates
+	res.Path = p.findFile(candidates)
+	p.addResolution(res)
+
+	if res.Path == "" {
+		return res.Candidates[0], nil
+	}
+
+	return res.Path, p.checkSymlinks(res.Path)
+}
+
+// resolveIncludePattern searches the include directories for files matching
+// glob pattern target and returns the matches of the first candidate with at
+// least one match.
+func (p *Parser) resolveIncludePattern(target string) ([]string, error) {
+	candidates, err := p.curIncludeCandidates(target)
+	if err != nil {
+		return nil, err
+	}
+
+	res := Resolution{File: p.curFile(), LineNr: p.curLineNr(), Target: target}
+	res.Candidates = candidates
+	res.Path, res.Matches, err = p.findPattern(candidates)
+	if err != nil {
+		return nil, &SyntaxError{p.curFile(), p.curLineNr(), "invalid pattern " + target}
+	}
+
+	p.addResolution(res)
+
+	for _, match := range res.Matches {
+		err = p.checkSymlinks(match)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res.Matches, nil
+}
+
+func (p *Parser) addResolution(res Resolution) {
+	p.logger().Debug("include resolved", "file", res.File, "line", res.LineNr,
+		"target", res.Target, "path", res.Path, "candidates", res.Candidates)
+	p.resolutions = append(p.resolutions, res)
 }
 
-func (p *Parser) handleInclude(line string) error {
-	incPath := p.resolveIncludeFile(strings.TrimPrefix(line, "Include "))
+// includeFiles parses the files matching include target. Missing files
+// are an error only if required is true.
+func (p *Parser) includeFiles(target string, required bool) error {
+	target = strings.TrimSpace(target)
+	if !hasMeta(target) {
+		path, err := p.resolveIncludeFile(target)
+		if err != nil {
+			return err
+		}
 
-	file, err := os.Open(incPath)
+		return p.includeFile(path, required)
+	}
+
+	paths, err := p.resolveIncludePattern(target)
 	if err != nil {
+		return err
+	}
+
+	if len(paths) == 0 {
+		if required {
+			return &SyntaxError{p.curFile(), p.curLineNr(), "no files match " + target}
+		}
+
+		p.logger().Info("include skipped", "file", p.curFile(), "line", p.curLineNr(),
+			"path", target, "reason", "no match")
+	}
+
+	for _, path := range paths {
+		err = p.includeFile(path, required)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) includeFile(path string, required bool) error {
+	if p.skipRepeat(path) {
+		p.logger().Debug("include skipped", "file", p.curFile(), "line", p.curLineNr(),
+			"path", path, "reason", "repeated")
 		return nil
 	}
-	defer file.Close()
 
-	return p.parseReader(file, incPath)
+	reader, err := p.openFile(path)
+	if err != nil {
+		if required || !errors.Is(err, fs.ErrNotExist) {
+			return &ReadError{p.curFile(), p.curLineNr(), err}
+		}
+
+		p.logger().Warn("include skipped", "file", p.curFile(), "line", p.curLineNr(),
+			"path", path, "reason", "missing", "error", err)
+		p.warn("optional include not found: " + path)
+		p.missing = append(p.missing, path)
+		return nil
+	}
+	defer reader.Close()
+
+	return p.readLines(reader, path, p.handleLine)
 }
 
-func (p *Parser) handleRequire(line string) error {
-	incPath := p.resolveIncludeFile(strings.TrimPrefix(line, "Require "))
-	return p.ParseFile(incPath)
+// skipRepeat tests if the inclusion of file path should be skipped
+// according to the repeated include policy.
+func (p *Parser) skipRepeat(path string) bool {
+	return p.Repeat == RepeatOnce && p.isVisited(path) && !p.isParsing(path)
 }
 
 func (p *Parser) setCurSection(section string) error {
@@ -219,45 +702,51 @@ func (p *Parser) setCurSection(section string) error {
 		return &SyntaxError{p.curFile(), p.curLineNr(), "empty section name"}
 	}
 
+	if p.labels[section] == nil {
+		if p.Limits.MaxSections > 0 && len(p.labels) >= p.Limits.MaxSections {
+			return &LimitError{p.curFile(), p.curLineNr(), ErrSections}
+		}
+
+		p.labels[section] = make(map[string]bool)
+	}
+
 	p.curSection = section
 	return nil
 }
 
-func (p *Parser) handleLine(line string) error {
-	p.incLineNr()
+func (p *Parser) handleLine(line string, tok token) error {
+	if tok.kind == tokenSection {
+		name, args, ok := p.splitDirective(tok.name)
+		if ok && isCondDirective(name) {
+			return p.handleCond(name, args)
+		}
+	}
 
-	// Remove comments and clean string.
-	cleanLine := removeComments(strings.TrimSpace(line))
-	if cleanLine == "" {
+	if p.skipping() {
 		return nil
 	}
 
-	// Section.
-	secRv, secName := readSectionName(cleanLine)
-	if secRv {
-		if strings.HasPrefix(secName, "Require ") {
-			return p.handleRequire(secName)
-		} else if strings.HasPrefix(secName, "Include ") {
-			return p.handleInclude(secName)
-		} else {
-			return p.setCurSection(secName)
+	switch tok.kind {
+	case tokenSection:
+		name, args, ok := p.splitDirective(tok.name)
+		if ok {
+			return p.handleDirective(name, args, tok.nameCol)
 		}
-	}
 
-	// Append operator.
-	apRv, apLabel, apValue := readAppend(cleanLine)
-	if apRv {
-		p.curLabel = apLabel
-		return p.insertValue(p.curSection, apLabel, apValue, true)
-	}
+		return p.setCurSection(tok.name)
 
-	// Assign operator.
-	asRv, asLabel, asValue := readAssign(cleanLine)
-	if asRv {
-		p.curLabel = asLabel
-		return p.insertValue(p.curSection, asLabel, asValue, false)
+	case tokenAppend:
+		p.curLabel = tok.name
+		return p.insertValue(p.curSection, tok.name, tok.value, true)
+
+	case tokenAssign:
+		p.curLabel = tok.name
+		return p.insertValue(p.curSection, tok.name, tok.value, false)
+
+	case tokenValue:
+		// Multi-line value.
+		return p.insertValue(p.curSection, p.curLabel, tok.value, true)
 	}
 
-	// Multi-line value.
-	return p.insertValue(p.curSection, p.curLabel, asValue, true)
+	return nil
 }
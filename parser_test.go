@@ -19,6 +19,15 @@
 package ini
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -317,3 +326,718 @@ func TestIncludeLoop(t *testing.T) {
 		t.Errorf("expected error")
 	}
 }
+
+// failingReader returns data and then fails with err.
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(b []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+
+	n := copy(b, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// observingReader returns one line per read and calls fn before each read.
+type observingReader struct {
+	lines []string
+	fn    func()
+}
+
+func (r *observingReader) Read(b []byte) (int, error) {
+	r.fn()
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, r.lines[0])
+	r.lines = r.lines[1:]
+	return n, nil
+}
+
+func TestParsePublishedAtEnd(t *testing.T) {
+	c := NewConfig()
+	p := NewParser(c)
+
+	reader := &observingReader{lines: []string{"[S0]\n", "L0 = V0\n", "L1 = V1\n"}}
+	reader.fn = func() {
+		if len(c.Sections()) > 1 || c.Value("S0", "L0") != "" {
+			t.Errorf("values published during the parse: %v", c.Map())
+		}
+
+		c.SetValue("Other", "L0", "V0")
+	}
+
+	err := p.Parse(reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"S0":    {"L0": "V0", "L1": "V1"},
+		"Other": {"L0": "V0"},
+	}
+
+	if !reflect.DeepEqual(expected, c.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, c.Map())
+	}
+}
+
+func TestParseReadError(t *testing.T) {
+	readErr := errors.New("device failure")
+
+	p := NewParser(nil)
+	err := p.Parse(&failingReader{"[Section]\nLabel = Value\n", readErr})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !errors.Is(err, readErr) {
+		t.Errorf("expected wrapped error %v, actual: %v", readErr, err)
+	}
+
+	actual := err.Error()
+	expected := "3: read error: device failure"
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	// Values read before the failure are kept.
+	if p.Config.Value("Section", "Label") != "Value" {
+		t.Errorf("expected value to be parsed before failure")
+	}
+}
+
+func TestParseReadErrorPath(t *testing.T) {
+	p := NewParser(nil)
+	err := p.parseReader(&failingReader{"[Section]\nLabel = Val", errors.New("eio")}, "dir/file.ini")
+
+	var readErr *ReadError
+	if !errors.As(err, &readErr) {
+		t.Fatalf("expected *ReadError, actual: %v", err)
+	}
+
+	actual := err.Error()
+	expected := "dir/file.ini:2: read error: eio"
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestDiamondRepeatAllow(t *testing.T) {
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/diamond.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"diamond":        {"diamond L0": "diamond V0"},
+		"diamond_a":      {"diamond_a L0": "diamond_a V0"},
+		"diamond_b":      {"diamond_b L0": "diamond_b V0"},
+		"diamond_common": {"diamond_common L0": "diamond_common V0 diamond_common V0"},
+	}
+
+	actual := p.Config.Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("\nexpected: %q\nactual: %q", expected, actual)
+	}
+}
+
+func TestDiamondRepeatOnce(t *testing.T) {
+	p := NewParser(nil)
+	p.Repeat = RepeatOnce
+	err := p.ParseFile("testdata/diamond.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "diamond_common V0"
+	actual := p.Config.Value("diamond_common", "diamond_common L0")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	// A new parse starts with an empty set of visited files.
+	err = p.ParseFile("testdata/diamond_common.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected = "diamond_common V0 diamond_common V0"
+	actual = p.Config.Value("diamond_common", "diamond_common L0")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestDiamondRepeatError(t *testing.T) {
+	p := NewParser(nil)
+	p.Repeat = RepeatError
+	err := p.ParseFile("testdata/diamond.ini")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	actual := err.Error()
+	expected := "testdata/diamond_b.ini:4: repeated include of testdata/diamond_common.ini"
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestRequireLoopRepeatOnce(t *testing.T) {
+	p := NewParser(nil)
+	p.Repeat = RepeatOnce
+	err := p.ParseFile("testdata/require_loop.ini")
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestIncludePath(t *testing.T) {
+	t.Setenv(IncludePathEnv, "")
+
+	p := NewParser(nil)
+	p.IncludePath = []string{"testdata/lib"}
+	err := p.ParseFile("testdata/search.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"search": {"search L0": "search V0"},
+		"include00": {
+			"include00 L0": "include00 V0",
+			"include00 L1": "include00 V1",
+		},
+		"search_lib": {"search_lib L0": "search_lib V0"},
+	}
+
+	actual := p.Config.Map()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("\nexpected: %q\nactual: %q", expected, actual)
+	}
+
+	expectedRes := []Resolution{
+		{
+			File:       "testdata/search.ini",
+			LineNr:     4,
+			Target:     "include00.ini",
+			Candidates: []string{"testdata/include00.ini", "testdata/lib/include00.ini"},
+			Path:       "testdata/include00.ini",
+		},
+		{
+			File:       "testdata/search.ini",
+			LineNr:     5,
+			Target:     "search_lib.ini",
+			Candidates: []string{"testdata/search_lib.ini", "testdata/lib/search_lib.ini"},
+			Path:       "testdata/lib/search_lib.ini",
+		},
+	}
+
+	actualRes := p.Resolutions()
+	if !reflect.DeepEqual(expectedRes, actualRes) {
+		t.Errorf("\nexpected: %+v\nactual: %+v", expectedRes, actualRes)
+	}
+}
+
+func TestIncludePathEnv(t *testing.T) {
+	t.Setenv(IncludePathEnv, "testdata/__no_such_dir"+string(filepath.ListSeparator)+"testdata/lib")
+
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/search.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "search_lib V0"
+	actual := p.Config.Value("search_lib", "search_lib L0")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestIncludePathNotFound(t *testing.T) {
+	t.Setenv(IncludePathEnv, "")
+
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/search.ini")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	res := p.Resolutions()
+	if len(res) != 2 || res[1].Path != "" {
+		t.Errorf("expected unresolved target, actual: %+v", res)
+	}
+}
+
+func TestIncludeGlob(t *testing.T) {
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/glob_include.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "a b"
+	actual := p.Config.Value("glob", "Order")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+func TestRequireGlob(t *testing.T) {
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/glob_require.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "a b c d"
+	actual := p.Config.Value("glob", "Order")
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	res := p.Resolutions()
+	if len(res) != 1 || len(res[0].Matches) != 4 {
+		t.Errorf("expected four matches, actual: %+v", res)
+	}
+}
+
+func TestIncludeGlobNoMatch(t *testing.T) {
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/glob_include_none.ini")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRequireGlobNoMatch(t *testing.T) {
+	p := NewParser(nil)
+	err := p.ParseFile("testdata/glob_require_none.ini")
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	actual := err.Error()
+	expected := "testdata/glob_require_none.ini:1: no files match conf.d/none/*.ini"
+	if actual != expected {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+}
+
+// recordHandler is a slog.Handler that records messages and attributes.
+type recordHandler struct {
+	level   slog.Level
+	records []string
+}
+
+func (h *recordHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	text := r.Level.String() + " " + r.Message
+	r.Attrs(func(a slog.Attr) bool {
+		text += " " + a.String()
+		return true
+	})
+
+	h.records = append(h.records, text)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *recordHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func TestLogger(t *testing.T) {
+	t.Setenv(IncludePathEnv, "")
+
+	h := &recordHandler{level: slog.LevelDebug}
+	p := NewParser(nil)
+	p.Logger = slog.New(h)
+	p.Repeat = RepeatOnce
+
+	err := p.ParseFile("testdata/include_ignore.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = p.Parse(strings.NewReader("[valid00]\nvalid00 L0 = V2\nvalid00 L1 = valid00 V1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"DEBUG parsing file path=testdata/include_ignore.ini depth=0",
+		"DEBUG include resolved file=testdata/include_ignore.ini line=5 " +
+			"target=__non_existent_file__ path= candidates=[testdata/__non_existent_file__]",
+		"WARN include skipped file=testdata/include_ignore.ini line=5 " +
+			"path=testdata/__non_existent_file__ reason=missing " +
+			"error=open testdata/__non_existent_file__: no such file or directory",
+		"DEBUG parsing file path= depth=0",
+		"INFO value overridden file= line=2 section=valid00 label=valid00 L0 old=valid00 V0 new=V2",
+	}
+
+	if !reflect.DeepEqual(expected, h.records) {
+		t.Errorf("\nexpected: %q\nactual: %q", expected, h.records)
+	}
+}
+
+func TestLoggerLevel(t *testing.T) {
+	h := &recordHandler{level: slog.LevelWarn}
+	p := NewParser(nil)
+	p.Logger = slog.New(h)
+
+	err := p.ParseFile("testdata/diamond.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(h.records) != 0 {
+		t.Errorf("unexpected records: %q", h.records)
+	}
+}
+
+func TestIncludeMissingWarning(t *testing.T) {
+	var warnings []string
+
+	p := NewParser(nil)
+	p.OnWarning = func(w *Warning) {
+		warnings = append(warnings, w.Error())
+	}
+
+	err := p.ParseFile("testdata/include_ignore.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"testdata/include_ignore.ini:5: warning: optional include not found: testdata/__non_existent_file__",
+	}
+
+	if !reflect.DeepEqual(expected, warnings) {
+		t.Errorf("\nexpected: %q\nactual: %q", expected, warnings)
+	}
+}
+
+func TestParseContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := NewParser(nil)
+	err := p.ParseContext(ctx, strings.NewReader("[Section]\nLabel = Value\n"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context error, actual: %v", err)
+	}
+
+	err = p.ParseFileContext(ctx, "testdata/valid00.ini")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context error, actual: %v", err)
+	}
+}
+
+// cancelReader cancels a context when data is read.
+type cancelReader struct {
+	data   string
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(b []byte) (int, error) {
+	r.cancel()
+	if r.data == "" {
+		return 0, io.EOF
+	}
+
+	n := copy(b, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestParseContextCanceledWhileParsing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := NewParser(nil)
+	err := p.ParseContext(ctx, &cancelReader{"[Section]\nLabel = Value\nOther = Value\n", cancel})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context error, actual: %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var tests = []struct {
+		path   string
+		limits Limits
+		err    error
+		msg    string
+	}{
+		{"testdata/valid00.ini", Limits{MaxLineLength: 22}, ErrLineLength,
+			"testdata/valid00.ini:2: line too long"},
+		{"testdata/valid00.ini", Limits{MaxLineLength: 27}, nil, ""},
+		{"testdata/valid00.ini", Limits{MaxBytes: 40}, ErrBytes,
+			"testdata/valid00.ini:3: too many bytes"},
+		{"testdata/diamond.ini", Limits{MaxIncludeDepth: 1}, ErrIncludeDepth,
+			"testdata/diamond_a.ini:4: include depth exceeded"},
+		{"testdata/diamond.ini", Limits{MaxIncludeDepth: 2}, nil, ""},
+		{"testdata/diamond.ini", Limits{MaxFiles: 4}, ErrFiles,
+			"testdata/diamond_b.ini:4: too many files"},
+		{"testdata/diamond.ini", Limits{MaxFiles: 5}, nil, ""},
+		{"testdata/diamond.ini", Limits{MaxSections: 3}, ErrSections,
+			"testdata/diamond_b.ini:1: too many sections"},
+		{"testdata/valid00.ini", Limits{MaxLabels: 1}, ErrLabels,
+			"testdata/valid00.ini:3: too many labels"},
+		{"testdata/valid00.ini", Limits{MaxLabels: 2}, nil, ""},
+	}
+
+	for idx, tt := range tests {
+		p := NewParser(nil)
+		p.Limits = tt.limits
+
+		err := p.ParseFile(tt.path)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("idx: %d, unexpected error: %v", idx, err)
+			}
+
+			continue
+		}
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("idx: %d, expected: %v, actual: %v", idx, tt.err, err)
+			continue
+		}
+
+		if err.Error() != tt.msg {
+			t.Errorf("idx: %d, expected: %q, actual: %q", idx, tt.msg, err.Error())
+		}
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	bio := bufio.NewReaderSize(strings.NewReader(long+"\n"+long), 16)
+
+	line, err := readLine(bio, 10000, -1)
+	if err != nil || line != long+"\n" {
+		t.Errorf("unexpected line of length %d, error: %v", len(line), err)
+	}
+
+	_, err = readLine(bio, 9999, -1)
+	if err != ErrLineLength {
+		t.Errorf("expected: %v, actual: %v", ErrLineLength, err)
+	}
+
+	bio = bufio.NewReaderSize(strings.NewReader(long+"\n"), 16)
+	_, err = readLine(bio, 0, 10000)
+	if err != ErrBytes {
+		t.Errorf("expected: %v, actual: %v", ErrBytes, err)
+	}
+}
+
+// endlessReader reads an endless line, counting the bytes read.
+type endlessReader struct {
+	nrBytes int64
+}
+
+func (r *endlessReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 'x'
+	}
+
+	r.nrBytes += int64(len(b))
+	return len(b), nil
+}
+
+func TestMaxBytesLongLine(t *testing.T) {
+	reader := &endlessReader{}
+
+	p := NewParser(nil)
+	p.Limits.MaxBytes = 1 << 16
+	err := p.Parse(io.MultiReader(strings.NewReader("[Section]\n"), reader))
+	if !errors.Is(err, ErrBytes) {
+		t.Fatalf("expected: %v, actual: %v", ErrBytes, err)
+	}
+
+	expected := "2: too many bytes"
+	if err.Error() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, err.Error())
+	}
+
+	if reader.nrBytes > 2*p.Limits.MaxBytes {
+		t.Errorf("read %d bytes past the limit", reader.nrBytes)
+	}
+}
+
+func TestParserReuse(t *testing.T) {
+	p := NewParser(nil)
+	err := p.Parse(strings.NewReader("[A]\nx = 1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = p.Parse(strings.NewReader("y = 2\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"A": {"x": "1", "y": "2"}}
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+}
+
+// writeFiles creates files with the given contents under folder dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(data), 0644)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside.ini")
+	writeFiles(t, dir, map[string]string{
+		"outside.ini":          "[outside]\nL0 = V0\n",
+		"root/main.ini":        "[Require sub/inner.ini]\n",
+		"root/sub/inner.ini":   "[inner]\nL0 = V0\n[Require ../common.ini]\n",
+		"root/common.ini":      "[common]\nL0 = V0\n",
+		"root/escape.ini":      "[Require ../outside.ini]\n",
+		"root/absolute.ini":    "[Include " + outside + "]\n",
+		"root/glob.ini":        "[Include ../*.ini]\n",
+		"root/include_dir.ini": "[Require outside.ini]\n",
+	})
+
+	root := filepath.Join(dir, "root")
+
+	var tests = []struct {
+		name string
+		msg  string
+	}{
+		{"main.ini", ""},
+		{"escape.ini", "escape.ini:1: include outside sandbox: ../outside.ini"},
+		{"absolute.ini", "absolute.ini:1: include outside sandbox: " + outside},
+		{"glob.ini", "glob.ini:1: include outside sandbox: ../*.ini"},
+	}
+
+	for idx, tt := range tests {
+		p := NewParser(nil)
+		p.Sandbox.Root = root
+
+		err := p.ParseFile(filepath.Join(root, tt.name))
+		if tt.msg == "" {
+			if err != nil {
+				t.Errorf("idx: %d, unexpected error: %v", idx, err)
+			}
+
+			continue
+		}
+
+		var secErr *SecurityError
+		if !errors.As(err, &secErr) {
+			t.Errorf("idx: %d, expected *SecurityError, actual: %v", idx, err)
+			continue
+		}
+
+		expected := filepath.Join(root, tt.msg)
+		if err.Error() != expected {
+			t.Errorf("idx: %d, expected: %q, actual: %q", idx, expected, err.Error())
+		}
+	}
+
+	// Include folders outside the sandbox are not searched.
+	p := NewParser(nil)
+	p.IncludePath = []string{dir}
+	p.Sandbox.Root = root
+
+	err := p.ParseFile(filepath.Join(root, "include_dir.ini"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected: %v, actual: %v", fs.ErrNotExist, err)
+	}
+}
+
+func TestSandboxSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"outside.ini":   "[outside]\nL0 = V0\n",
+		"root/main.ini": "[Require link.ini]\n",
+	})
+
+	root := filepath.Join(dir, "root")
+	err := os.Symlink(filepath.Join(dir, "outside.ini"), filepath.Join(root, "link.ini"))
+	if err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+
+	p := NewParser(nil)
+	p.Sandbox.Root = root
+	err = p.ParseFile(filepath.Join(root, "main.ini"))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	p = NewParser(nil)
+	p.Sandbox = Sandbox{Root: root, Symlinks: true}
+	err = p.ParseFile(filepath.Join(root, "main.ini"))
+
+	var secErr *SecurityError
+	if !errors.As(err, &secErr) {
+		t.Fatalf("expected *SecurityError, actual: %v", err)
+	}
+
+	expected := filepath.Join(root, "main.ini") + ":1: symbolic link outside sandbox: " +
+		filepath.Join(root, "link.ini")
+	if err.Error() != expected {
+		t.Errorf("expected: %q, actual: %q", expected, err.Error())
+	}
+}
+
+// benchConfig returns a configuration with n sections.
+func benchConfig(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "# Task %d\n", i)
+		fmt.Fprintf(&b, "[Task/%d]\n", i)
+		b.WriteString("Enabled                                 = Always\n")
+		b.WriteString("Entity Label                            = Task ; Label\n")
+		b.WriteString("Execution Frequency                     = 10\n")
+		b.WriteString("Gains                                   += 1.0, 0.5\n")
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func BenchmarkParse(b *testing.B) {
+	input := benchConfig(1000)
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := NewParser(nil)
+		err := p.Parse(strings.NewReader(input))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
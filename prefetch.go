@@ -0,0 +1,205 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"context"
+	"sync"
+)
+
+// prefetcher reads and scans files concurrently ahead of the parser. Files
+// are discovered by following the Include and Require directives of the
+// files read, outside conditional blocks and unless overridden with
+// Parser.RegisterDirective, and each file is read at most once per parse.
+// The parser applies the contents of the files in the same order as
+// without prefetching.
+type prefetcher struct {
+	p      *Parser                    // Parser.
+	ctx    context.Context            // Context of the readers.
+	cancel context.CancelFunc         // Function to cancel the readers.
+	sem    chan struct{}              // Semaphore limiting concurrent reads.
+	wg     sync.WaitGroup             // Running readers.
+	lock   sync.Mutex                 // Lock of files.
+	files  map[string]*prefetchedFile // Files by key.
+}
+
+// prefetchedFile holds the contents of a file read in advance.
+type prefetchedFile struct {
+	done  chan struct{} // Closed when reading is complete.
+	lines []lineResult  // Lines read.
+	err   error         // Error opening the file.
+}
+
+func (p *Parser) startPrefetch() {
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.prefetch = &prefetcher{
+		p:      p,
+		ctx:    ctx,
+		cancel: cancel,
+		sem:    make(chan struct{}, p.Prefetch),
+		files:  make(map[string]*prefetchedFile),
+	}
+}
+
+func (p *Parser) stopPrefetch() {
+	p.prefetch.cancel()
+	p.prefetch.wg.Wait()
+	p.prefetch = nil
+}
+
+// open waits for file path to be read and returns a reader of its lines.
+// If the file cannot be prefetched a nil reader and error are returned.
+func (f *prefetcher) open(path string) (lineReader, error) {
+	file := f.schedule(path)
+	if file == nil {
+		return nil, nil
+	}
+
+	<-file.done
+	if file.err != nil {
+		return nil, file.err
+	}
+
+	return &bufferedReader{lines: file.lines}, nil
+}
+
+// schedule starts reading file path unless it was already started. No
+// more files than allowed by Limits.MaxFiles are read.
+func (f *prefetcher) schedule(path string) *prefetchedFile {
+	key := f.p.fs.Key(path)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	file, exists := f.files[key]
+	if exists {
+		return file
+	}
+
+	if f.p.Limits.MaxFiles > 0 && len(f.files) >= f.p.Limits.MaxFiles {
+		return nil
+	}
+
+	file = &prefetchedFile{done: make(chan struct{})}
+	f.files[key] = file
+
+	f.wg.Add(1)
+	go f.read(path, file)
+	return file
+}
+
+// read reads file path and schedules the files it includes.
+func (f *prefetcher) read(path string, file *prefetchedFile) {
+	defer f.wg.Done()
+
+	select {
+	case f.sem <- struct{}{}:
+	case <-f.ctx.Done():
+		file.err = f.ctx.Err()
+		close(file.done)
+		return
+	}
+
+	f.readLines(path, file)
+	<-f.sem
+	close(file.done)
+
+	// Files included in conditional blocks are read when the parser needs
+	// them, since it may skip the block.
+	depth := 0
+	for _, res := range file.lines {
+		if res.tok.kind != tokenSection {
+			continue
+		}
+
+		name, args, ok := f.p.splitDirective(res.tok.name)
+		switch {
+		case !ok:
+		case name == "If":
+			depth++
+		case name == "EndIf":
+			depth = max(depth-1, 0)
+		case depth == 0 && f.p.isBuiltinInclude(name):
+			for _, target := range f.resolve(path, args) {
+				f.schedule(target)
+			}
+		}
+	}
+}
+
+// readLines reads the lines of file path. Reading stops early if the file
+// alone exceeds the limit on the number of bytes of a parse, since the
+// parser will stop there.
+func (f *prefetcher) readLines(path string, file *prefetchedFile) {
+	f.p.logger().Debug("prefetching file", "path", path)
+
+	fd, err := f.p.open(path)
+	if err != nil {
+		file.err = err
+		return
+	}
+	defer fd.Close()
+
+	var nrBytes int64
+
+	reader := f.p.newStreamReader(fd, nil)
+	reader.maxBytes = f.p.byteBudget(&nrBytes)
+
+	for {
+		err = f.ctx.Err()
+		if err != nil {
+			file.lines = append(file.lines, lineResult{err: err})
+			return
+		}
+
+		res := reader.next()
+		file.lines = append(file.lines, res)
+		if res.err != nil {
+			return
+		}
+
+		nrBytes += int64(len(res.line))
+		if f.p.Limits.MaxBytes > 0 && nrBytes > f.p.Limits.MaxBytes {
+			return
+		}
+	}
+}
+
+// resolve returns the files matching target included by file, as the
+// parser would resolve them. Files outside the sandbox are discarded.
+func (f *prefetcher) resolve(file string, target string) []string {
+	p := f.p
+	candidates := p.includeCandidates(file, target)
+
+	var paths []string
+	if hasMeta(target) {
+		_, paths, _ = p.findPattern(candidates)
+	} else if path := p.findFile(candidates); path != "" {
+		paths = []string{path}
+	}
+
+	var confined []string
+	for _, path := range paths {
+		if !p.escapesSandbox(path) {
+			confined = append(confined, path)
+		}
+	}
+
+	return confined
+}
@@ -0,0 +1,240 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"fmt"
+	"io/fs"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// parseResult holds the outcome of a parse.
+type parseResult struct {
+	cfg         map[string]map[string]string
+	err         string
+	resolutions []Resolution
+}
+
+func parseWith(prefetch int, limits Limits, parse func(p *Parser) error) parseResult {
+	p := NewParser(nil)
+	p.Prefetch = prefetch
+	p.Limits = limits
+
+	var res parseResult
+	if err := parse(p); err != nil {
+		res.err = err.Error()
+	}
+
+	res.cfg = p.Config.Map()
+	res.resolutions = p.Resolutions()
+	return res
+}
+
+func TestPrefetchDifferential(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini": newMapFile("[main]\nL0 = V0\n" +
+			"[Require a.ini]\n[Include b.ini]\n[Include missing.ini]\n" +
+			"[Require conf.d/*.ini]\n[main]\nL1 = V1\n"),
+		"a.ini":        newMapFile("[a]\nL0 += A\n[Require common.ini]\n"),
+		"b.ini":        newMapFile("[b]\nL0 += B\n[Require common.ini]\n[Require a.ini]\n"),
+		"common.ini":   newMapFile("[common]\nL0 += C\n"),
+		"conf.d/x.ini": newMapFile("[conf]\nOrder += x\n"),
+		"conf.d/y.ini": newMapFile("[conf]\nOrder += y\n[Require ../common.ini]\n"),
+		"loop.ini":     newMapFile("[Require loop2.ini]\n"),
+		"loop2.ini":    newMapFile("[Require loop.ini]\n"),
+	}
+
+	var tests = []struct {
+		limits Limits
+		parse  func(p *Parser) error
+	}{
+		{Limits{}, func(p *Parser) error { return p.ParseFS(fsys, "main.ini") }},
+		{Limits{}, func(p *Parser) error { return p.ParseFS(fsys, "loop.ini") }},
+		{Limits{}, func(p *Parser) error { return p.ParseFS(fsys, "missing.ini") }},
+		{Limits{MaxFiles: 4}, func(p *Parser) error { return p.ParseFS(fsys, "main.ini") }},
+		{Limits{MaxBytes: 50}, func(p *Parser) error { return p.ParseFS(fsys, "main.ini") }},
+		{Limits{MaxLineLength: 10}, func(p *Parser) error { return p.ParseFS(fsys, "main.ini") }},
+		{Limits{}, func(p *Parser) error { return p.ParseFile("testdata/diamond.ini") }},
+		{Limits{}, func(p *Parser) error { return p.ParseFile("testdata/glob_require.ini") }},
+		{Limits{}, func(p *Parser) error { return p.ParseFile("testdata/include_ignore.ini") }},
+		{Limits{}, func(p *Parser) error { return p.ParseFile("testdata/require_loop.ini") }},
+	}
+
+	for idx, tt := range tests {
+		expected := parseWith(0, tt.limits, tt.parse)
+		for _, prefetch := range []int{1, 4} {
+			actual := parseWith(prefetch, tt.limits, tt.parse)
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("idx: %d, prefetch: %d\nexpected: %+v\nactual: %+v",
+					idx, prefetch, expected, actual)
+			}
+		}
+	}
+}
+
+// slowFS delays opening files to simulate slow storage.
+type slowFS struct {
+	fstest.MapFS
+	delay time.Duration
+}
+
+func (f slowFS) Open(name string) (fs.File, error) {
+	time.Sleep(f.delay)
+	return f.MapFS.Open(name)
+}
+
+// includeTree returns a file system where each file requires width files,
+// down to the given depth.
+func includeTree(width int, depth int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+
+	var add func(name string, level int)
+	add = func(name string, level int) {
+		data := fmt.Sprintf("[%s]\nLevel = %d\n", name, level)
+		if level < depth {
+			for i := 0; i < width; i++ {
+				child := fmt.Sprintf("%s_%d", name, i)
+				data += fmt.Sprintf("[Require %s.ini]\n", child)
+				add(child, level+1)
+			}
+		}
+
+		fsys[name+".ini"] = newMapFile(data)
+	}
+
+	add("root", 0)
+	return fsys
+}
+
+func TestPrefetchIncludeTree(t *testing.T) {
+	fsys := includeTree(3, 3)
+
+	expected := parseWith(0, Limits{}, func(p *Parser) error { return p.ParseFS(fsys, "root.ini") })
+	actual := parseWith(8, Limits{}, func(p *Parser) error { return p.ParseFS(fsys, "root.ini") })
+
+	if expected.err != "" || len(expected.cfg) != 40 {
+		t.Fatalf("unexpected result: %+v", expected)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("\nexpected: %+v\nactual: %+v", expected, actual)
+	}
+}
+
+// openLogFS records the files opened from a file system.
+type openLogFS struct {
+	fs.FS
+	lock   sync.Mutex
+	opened []string
+}
+
+func (f *openLogFS) Open(name string) (fs.File, error) {
+	f.lock.Lock()
+	f.opened = append(f.opened, name)
+	f.lock.Unlock()
+
+	return f.FS.Open(name)
+}
+
+func TestPrefetchConditionals(t *testing.T) {
+	fsys := &openLogFS{FS: fstest.MapFS{
+		"main.ini": newMapFile("[If P == A]\n[Require a.ini]\n[Else]\n[Require b.ini]\n[EndIf]\n"),
+		"a.ini":    newMapFile("[A]\nL0 = V0\n[Include c.ini]\n"),
+		"b.ini":    newMapFile("[B]\nL0 = V0\n"),
+		"c.ini":    newMapFile("[C]\nL0 = V0\n"),
+	}}
+
+	p := NewParser(nil)
+	p.Prefetch = 4
+	p.Variables = map[string]string{"P": "A"}
+	err := p.ParseFS(fsys, "main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"A": {"L0": "V0"}, "C": {"L0": "V0"}}
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+
+	for _, name := range fsys.opened {
+		if name == "b.ini" {
+			t.Errorf("file of skipped block read: %q", fsys.opened)
+		}
+	}
+}
+
+func TestPrefetchOverriddenInclude(t *testing.T) {
+	fsys := &openLogFS{FS: fstest.MapFS{
+		"main.ini": newMapFile("[Include b.ini]\n[Require a.ini]\n"),
+		"a.ini":    newMapFile("[A]\nL0 = V0\n"),
+		"b.ini":    newMapFile("[B]\nL0 = V0\n"),
+	}}
+
+	p := NewParser(nil)
+	p.Prefetch = 4
+	p.RegisterDirective("Include", func(d *Directive) error {
+		return nil
+	})
+
+	err := p.ParseFS(fsys, "main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{"A": {"L0": "V0"}}
+	if !reflect.DeepEqual(expected, p.Config.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, p.Config.Map())
+	}
+
+	for _, name := range fsys.opened {
+		if name == "b.ini" {
+			t.Errorf("file of overridden directive read: %q", fsys.opened)
+		}
+	}
+}
+
+func benchmarkPrefetch(b *testing.B, prefetch int) {
+	fsys := slowFS{includeTree(4, 3), time.Millisecond}
+
+	for i := 0; i < b.N; i++ {
+		p := NewParser(nil)
+		p.Prefetch = prefetch
+		err := p.ParseFS(fsys, "root.ini")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPrefetchDisabled(b *testing.B) {
+	benchmarkPrefetch(b, 0)
+}
+
+func BenchmarkPrefetch4(b *testing.B) {
+	benchmarkPrefetch(b, 4)
+}
+
+func BenchmarkPrefetch16(b *testing.B) {
+	benchmarkPrefetch(b, 16)
+}
@@ -0,0 +1,124 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"bufio"
+	"io"
+)
+
+// lineResult is the result of reading a line.
+type lineResult struct {
+	line string // Line, including the delimiter.
+	tok  token  // Components of the line.
+	err  error  // Error of reading the line, see readLine.
+}
+
+// lineReader reads the lines of a file.
+type lineReader interface {
+	// next reads the next line.
+	next() lineResult
+	// Close releases the resources of the reader.
+	Close() error
+}
+
+// streamReader reads lines from a stream.
+type streamReader struct {
+	bio       *bufio.Reader // Buffered stream.
+	closer    io.Closer     // Closer of the stream, may be nil.
+	maxLength int           // Maximum line length.
+	maxBytes  func() int64  // Number of bytes that may still be read, see readLine.
+}
+
+func (p *Parser) newStreamReader(reader io.Reader, closer io.Closer) *streamReader {
+	return &streamReader{bufio.NewReader(reader), closer, p.Limits.MaxLineLength, p.byteBudget(&p.nrBytes)}
+}
+
+// byteBudget returns a function returning the number of bytes that may
+// still be read once *nrBytes bytes were read, or -1 if unlimited.
+func (p *Parser) byteBudget(nrBytes *int64) func() int64 {
+	return func() int64 {
+		if p.Limits.MaxBytes <= 0 {
+			return -1
+		}
+
+		return max(p.Limits.MaxBytes-*nrBytes, 0)
+	}
+}
+
+func (r *streamReader) next() lineResult {
+	line, err := readLine(r.bio, r.maxLength, r.maxBytes())
+	return lineResult{line, scanLine(line), err}
+}
+
+func (r *streamReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
+
+// bufferedReader replays lines read in advance. The last line must have a
+// non-nil error.
+type bufferedReader struct {
+	lines []lineResult // Lines read.
+	pos   int          // Index of the next line.
+}
+
+func (r *bufferedReader) next() lineResult {
+	res := r.lines[r.pos]
+	r.pos++
+	return res
+}
+
+func (r *bufferedReader) Close() error {
+	return nil
+}
+
+// readLine reads a line from bio, including the delimiter. If maxLength is
+// positive, lines longer than maxLength bytes, excluding the delimiter, are
+// rejected with ErrLineLength. If maxBytes is not negative, lines longer than
+// maxBytes bytes, including the delimiter, are rejected with ErrBytes as soon
+// as the limit is exceeded.
+func readLine(bio *bufio.Reader, maxLength int, maxBytes int64) (string, error) {
+	var line []byte
+
+	for {
+		chunk, err := bio.ReadSlice('\n')
+		line = append(line, chunk...)
+
+		length := len(line)
+		if err == nil {
+			length--
+		}
+
+		if maxLength > 0 && length > maxLength {
+			return "", ErrLineLength
+		}
+
+		if maxBytes >= 0 && int64(len(line)) > maxBytes {
+			return "", ErrBytes
+		}
+
+		if err != bufio.ErrBufferFull {
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,393 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the polling interval used by Reloader.Run when
+// Interval is zero.
+const DefaultReloadInterval = 2 * time.Second
+
+// Reloader keeps a Config up to date with a tree of configuration files. It
+// parses a root file, remembers every file pulled in by Require and Include
+// directives, and polls their modification time and contents, as well as the
+// files matching the patterns of Include and Require directives. When a file
+// changes, or a file matching a pattern is created or removed, the tree is
+// parsed again and the result replaces the contents of Config atomically. A
+// failed parse leaves Config unmodified.
+type Reloader struct {
+	Config    *Config           // Configuration kept up to date.
+	Path      string            // Root file.
+	FS        fs.FS             // File system of the files, nil for the host file system.
+	Interval  time.Duration     // Polling interval of Run.
+	Configure func(p *Parser)   // Function called to configure each parser, may be nil.
+	OnReload  func()            // Function called after each successful reload, may be nil.
+	OnError   func(error)       // Function called with errors reported by Run, may be nil.
+	lock      sync.Mutex        // Serializes loads and checks.
+	stamps    map[string]stamp  // State of the watched files.
+	order     []string          // Watched files, in order.
+	patterns  map[string]string // Watched patterns and their matches.
+}
+
+// stamp is the observed state of a file.
+type stamp struct {
+	exists  bool              // File exists.
+	modTime time.Time         // Modification time.
+	size    int64             // Size in bytes.
+	sum     [sha256.Size]byte // Hash of the contents.
+}
+
+// recorder records the state of the files read by a parse, as they were
+// read.
+type recorder struct {
+	lock   sync.Mutex       // Guards stamps.
+	stamps map[string]stamp // State of the files read to the end.
+}
+
+// open records file path as missing if it does not exist, or wraps file to
+// record its state once read to the end.
+func (rec *recorder) open(path string, file fs.File, err error) (fs.File, error) {
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			rec.record(path, stamp{})
+		}
+
+		return file, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return file, nil
+	}
+
+	st := stamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+	return &recordedFile{file, rec, path, st, sha256.New()}, nil
+}
+
+func (rec *recorder) record(path string, st stamp) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if rec.stamps == nil {
+		rec.stamps = make(map[string]stamp)
+	}
+
+	rec.stamps[path] = st
+}
+
+// lookup returns the recorded state of file path.
+func (rec *recorder) lookup(path string) (stamp, bool) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	st, ok := rec.stamps[path]
+	return st, ok
+}
+
+// recordedFile hashes the contents of a file as they are read.
+type recordedFile struct {
+	fs.File
+	rec  *recorder // Recorder of the parse.
+	path string    // File path.
+	st   stamp     // State of the file when opened.
+	hash hash.Hash // Hash of the contents read so far.
+}
+
+func (f *recordedFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.hash.Write(b[:n])
+	if err == io.EOF {
+		f.hash.Sum(f.st.sum[:0])
+		f.rec.record(f.path, f.st)
+	}
+
+	return n, err
+}
+
+// NewReloader creates a new instance of Reloader for root file path.
+func NewReloader(c *Config, path string) *Reloader {
+	if c == nil {
+		c = NewConfig()
+	}
+
+	return &Reloader{Config: c, Path: path}
+}
+
+// fs returns the file system of the watched files.
+func (r *Reloader) fs() fileSystem {
+	if r.FS == nil {
+		return hostFS{}
+	}
+
+	return ioFS{r.FS}
+}
+
+// Files returns the paths of the watched files, in the order they were
+// first parsed. Optional include files that were missing are watched too.
+func (r *Reloader) Files() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]string(nil), r.order...)
+}
+
+// Load parses the file tree and replaces the contents of Config with the
+// result. The changes are reported with source Path, and the values keep
+// the file and line they were parsed from, see Config.Source. If parsing
+// fails, Config is not modified and the error is returned. The files parsed
+// are watched in both cases, so a failed configuration is not parsed again
+// until it changes.
+func (r *Reloader) Load() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.load(context.Background())
+}
+
+func (r *Reloader) load(ctx context.Context) error {
+	p := NewParser(NewConfig())
+	if r.Configure != nil {
+		r.Configure(p)
+	}
+
+	p.recorder = &recorder{}
+
+	var err error
+	if r.FS == nil {
+		err = p.ParseFileContext(ctx, r.Path)
+	} else {
+		err = p.ParseFSContext(ctx, r.FS, r.Path)
+	}
+
+	files := append(p.Files(), p.missing...)
+	patterns := includePatterns(p.Resolutions())
+	if err != nil {
+		// Keep watching the files of the last good configuration.
+		files = append(files, r.order...)
+		for pattern, matches := range r.patterns {
+			if _, exists := patterns[pattern]; !exists {
+				patterns[pattern] = matches
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		files = []string{r.Path}
+	}
+
+	r.watch(files, p.recorder)
+	r.patterns = patterns
+
+	if err != nil {
+		return err
+	}
+
+	snap := p.Config.Snapshot()
+	r.Config.Update(func(tx *Tx) error {
+		tx.SetSource(r.Path)
+		tx.do(func(tx *Tx) {
+			tx.restore(snap)
+		})
+
+		return nil
+	})
+
+	if r.OnReload != nil {
+		r.OnReload()
+	}
+
+	return nil
+}
+
+// includePatterns returns the patterns of include directives that could
+// have matched files, with the files they matched. Patterns searched before
+// the one that matched had no matches.
+func includePatterns(resolutions []Resolution) map[string]string {
+	patterns := make(map[string]string)
+	for _, res := range resolutions {
+		if !hasMeta(res.Target) {
+			continue
+		}
+
+		for _, candidate := range res.Candidates {
+			if candidate == res.Path {
+				patterns[candidate] = strings.Join(res.Matches, "\n")
+				break
+			}
+
+			patterns[candidate] = ""
+		}
+	}
+
+	return patterns
+}
+
+// watch replaces the set of watched files. The state of the files is taken
+// from rec if they were read to the end by the parse, so that modifications
+// made after they were read are detected.
+func (r *Reloader) watch(files []string, rec *recorder) {
+	stamps := make(map[string]stamp)
+	var order []string
+
+	for _, path := range files {
+		if _, exists := stamps[path]; exists {
+			continue
+		}
+
+		st, ok := rec.lookup(path)
+		if !ok {
+			st = r.stamp(path, nil)
+		}
+
+		stamps[path] = st
+		order = append(order, path)
+	}
+
+	r.stamps = stamps
+	r.order = order
+}
+
+// matches returns the files matching pattern, separated by newlines.
+func (r *Reloader) matches(pattern string) string {
+	matches, err := glob(r.fs(), pattern)
+	if err != nil {
+		return ""
+	}
+
+	return strings.Join(matches, "\n")
+}
+
+// stamp returns the state of file path. If old is not nil and the
+// modification time and size are unchanged, the contents are not read.
+func (r *Reloader) stamp(path string, old *stamp) stamp {
+	fsys := r.fs()
+
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return stamp{}
+	}
+
+	st := stamp{exists: true, modTime: info.ModTime(), size: info.Size()}
+	if old != nil && old.exists && old.modTime.Equal(st.modTime) && old.size == st.size {
+		st.sum = old.sum
+		return st
+	}
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		return stamp{}
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return stamp{}
+	}
+
+	hash.Sum(st.sum[:0])
+	return st
+}
+
+// changed tests if any watched file was created, removed, or modified, or
+// the files matching any watched pattern changed, and records the new state
+// of the files.
+func (r *Reloader) changed() bool {
+	changed := false
+	for _, path := range r.order {
+		old := r.stamps[path]
+		cur := r.stamp(path, &old)
+		if cur.exists != old.exists || cur.sum != old.sum {
+			changed = true
+		}
+
+		r.stamps[path] = cur
+	}
+
+	for pattern, old := range r.patterns {
+		cur := r.matches(pattern)
+		if cur != old {
+			changed = true
+		}
+
+		r.patterns[pattern] = cur
+	}
+
+	return changed
+}
+
+// Check polls the watched files once and reloads the configuration if any
+// changed. It reports whether the configuration was reloaded, and the parse
+// error if reloading failed. Check loads the configuration if it was never
+// loaded.
+func (r *Reloader) Check() (bool, error) {
+	return r.check(context.Background())
+}
+
+func (r *Reloader) check(ctx context.Context) (bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stamps != nil && !r.changed() {
+		return false, nil
+	}
+
+	err := r.load(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Run polls the watched files every Interval until ctx is done, reloading
+// the configuration when they change, and returns the context error. Parse
+// errors are reported to OnError and do not stop polling.
+func (r *Reloader) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := r.check(ctx)
+		if err != nil && !errors.Is(err, ctx.Err()) && r.OnError != nil {
+			r.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,280 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.ini": "[General]\nSpeed = 1\n[Require lib.ini]\n[Include opt.ini]\n",
+		"lib.ini":  "[Lib]\nL0 = V0\n",
+	})
+
+	c := NewConfig()
+	c.SetValue("Stale", "L0", "V0")
+
+	r := NewReloader(c, filepath.Join(dir, "main.ini"))
+	err := r.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"General": {"Speed": "1"},
+		"Lib":     {"L0": "V0"},
+	}
+
+	if !reflect.DeepEqual(expected, c.Map()) {
+		t.Errorf("expected: %v, actual: %v", expected, c.Map())
+	}
+
+	var files []string
+	for _, name := range []string{"main.ini", "lib.ini", "opt.ini"} {
+		files = append(files, filepath.Join(dir, name))
+	}
+
+	if !reflect.DeepEqual(files, r.Files()) {
+		t.Errorf("expected: %q, actual: %q", files, r.Files())
+	}
+
+	steps := []struct {
+		files    map[string]string
+		reloaded bool
+		fails    bool
+		expected map[string]map[string]string
+	}{
+		// Unchanged.
+		{nil, false, false, expected},
+		// Same contents.
+		{map[string]string{"lib.ini": "[Lib]\nL0 = V0\n"}, false, false, expected},
+		// Included file modified.
+		{map[string]string{"lib.ini": "[Lib]\nL0 = V10\n"}, true, false,
+			map[string]map[string]string{"General": {"Speed": "1"}, "Lib": {"L0": "V10"}}},
+		// Parse error keeps last good configuration.
+		{map[string]string{"lib.ini": "[Lib]\n[Require missing.ini]\n"}, false, true,
+			map[string]map[string]string{"General": {"Speed": "1"}, "Lib": {"L0": "V10"}}},
+		// Failed configuration is not parsed again.
+		{nil, false, false,
+			map[string]map[string]string{"General": {"Speed": "1"}, "Lib": {"L0": "V10"}}},
+		// Fixed.
+		{map[string]string{"lib.ini": "[Lib]\nL0 = V2\n"}, true, false,
+			map[string]map[string]string{"General": {"Speed": "1"}, "Lib": {"L0": "V2"}}},
+		// Missing optional include created.
+		{map[string]string{"opt.ini": "[Opt]\nL0 = V0\n"}, true, false,
+			map[string]map[string]string{"General": {"Speed": "1"}, "Lib": {"L0": "V2"}, "Opt": {"L0": "V0"}}},
+	}
+
+	for i, step := range steps {
+		writeFiles(t, dir, step.files)
+
+		reloaded, err := r.Check()
+		if reloaded != step.reloaded || (err != nil) != step.fails {
+			t.Errorf("step %d: unexpected result: %v, %v", i, reloaded, err)
+		}
+
+		if !reflect.DeepEqual(step.expected, c.Map()) {
+			t.Errorf("step %d: expected: %v, actual: %v", i, step.expected, c.Map())
+		}
+	}
+}
+
+func TestReloaderGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.ini":       "[Include conf.d/*.ini]\n",
+		"conf.d/a.ini":   "[A]\nL0 = V0\n",
+		"conf.d/b.ini.0": "[B]\nL0 = V0\n",
+	})
+
+	r := NewReloader(nil, filepath.Join(dir, "main.ini"))
+	err := r.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	steps := []struct {
+		files    map[string]string
+		reloaded bool
+		expected map[string]map[string]string
+	}{
+		// Unchanged.
+		{nil, false, map[string]map[string]string{"A": {"L0": "V0"}}},
+		// File not matching the pattern created.
+		{map[string]string{"conf.d/c.txt": "[C]\n"}, false,
+			map[string]map[string]string{"A": {"L0": "V0"}}},
+		// File matching the pattern created.
+		{map[string]string{"conf.d/b.ini": "[B]\nL0 = V1\n"}, true,
+			map[string]map[string]string{"A": {"L0": "V0"}, "B": {"L0": "V1"}}},
+	}
+
+	for i, step := range steps {
+		writeFiles(t, dir, step.files)
+
+		reloaded, err := r.Check()
+		if reloaded != step.reloaded || err != nil {
+			t.Errorf("step %d: unexpected result: %v, %v", i, reloaded, err)
+		}
+
+		if !reflect.DeepEqual(step.expected, r.Config.Map()) {
+			t.Errorf("step %d: expected: %v, actual: %v", i, step.expected, r.Config.Map())
+		}
+	}
+
+	// Matching file removed.
+	err = os.Remove(filepath.Join(dir, "conf.d", "a.ini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := r.Check()
+	expected := map[string]map[string]string{"B": {"L0": "V1"}}
+	if !reloaded || err != nil || !reflect.DeepEqual(expected, r.Config.Map()) {
+		t.Errorf("unexpected result: %v, %v, %v", reloaded, err, r.Config.Map())
+	}
+}
+
+func TestReloaderModifiedDuringParse(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.ini": "[General]\nSpeed = 1\n[Require lib.ini]\n",
+		"lib.ini":  "[Lib]\nL0 = V0\n",
+	})
+
+	// Modify the root file after it was read, while the parse is running.
+	modified := false
+	r := NewReloader(nil, filepath.Join(dir, "main.ini"))
+	r.Configure = func(p *Parser) {
+		p.RegisterDirective("Require", func(d *Directive) error {
+			if !modified {
+				writeFiles(t, dir, map[string]string{"main.ini": "[General]\nSpeed = 2\n[Require lib.ini]\n"})
+				modified = true
+			}
+
+			return d.Parser.includeFiles(d.Args, true)
+		})
+	}
+
+	err := r.Load()
+	if err != nil || r.Config.Value("General", "Speed") != "1" {
+		t.Fatalf("unexpected result: %v, %v", err, r.Config.Map())
+	}
+
+	reloaded, err := r.Check()
+	if !reloaded || err != nil || r.Config.Value("General", "Speed") != "2" {
+		t.Errorf("unexpected result: %v, %v, %v", reloaded, err, r.Config.Map())
+	}
+}
+
+func TestReloaderFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/main.ini": newMapFile("[Require lib.ini]\n"),
+		"etc/lib.ini":  newMapFile("[Lib]\nL0 = V0\n"),
+	}
+
+	r := NewReloader(nil, "etc/main.ini")
+	r.FS = fsys
+	r.Configure = func(p *Parser) {
+		p.Repeat = RepeatError
+	}
+
+	reloaded, err := r.Check()
+	if !reloaded || err != nil {
+		t.Fatalf("unexpected result: %v, %v", reloaded, err)
+	}
+
+	ch := make(chan []Change, 1)
+	sub := r.Config.Subscribe(Filter{}, func(changes []Change) {
+		ch <- changes
+	})
+	defer sub.Unsubscribe()
+
+	fsys["etc/lib.ini"] = &fstest.MapFile{
+		Data:    []byte("[Lib]\nL0 = V1\n"),
+		ModTime: time.Now().Add(time.Second),
+	}
+
+	reloaded, err = r.Check()
+	if !reloaded || err != nil || r.Config.Value("Lib", "L0") != "V1" {
+		t.Errorf("unexpected result: %v, %v, %v", reloaded, err, r.Config.Map())
+	}
+
+	// Changes come from the reload, values from their files.
+	expected := []Change{{ValueModified, "Lib", "L0", "V0", "V1", "etc/main.ini"}}
+	actual := receiveWithin(t, ch)
+	if !reflect.DeepEqual(expected, actual) || r.Config.Source("Lib", "L0") != "etc/lib.ini:2" {
+		t.Errorf("expected: %v, actual: %v, source: %q", expected, actual, r.Config.Source("Lib", "L0"))
+	}
+
+	// Parser configuration applies to reloads.
+	fsys["etc/main.ini"] = newMapFile("[Require lib.ini]\n[Require lib.ini]\n")
+
+	_, err = r.Check()
+	if err == nil || r.Config.Value("Lib", "L0") != "V1" {
+		t.Errorf("unexpected result: %v, %v", err, r.Config.Map())
+	}
+}
+
+func TestReloaderRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.ini": "[General]\nSpeed = 1\n"})
+
+	r := NewReloader(nil, filepath.Join(dir, "main.ini"))
+	r.Interval = 10 * time.Millisecond
+
+	reloads := make(chan bool, 10)
+	r.OnReload = func() {
+		reloads <- true
+	}
+
+	errs := make(chan error, 10)
+	r.OnError = func(err error) {
+		errs <- err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- r.Run(ctx)
+	}()
+
+	receiveWithin(t, reloads)
+	writeFiles(t, dir, map[string]string{"main.ini": "[General]\nSpeed = 2.5\n"})
+	receiveWithin(t, reloads)
+
+	if r.Config.Value("General", "Speed") != "2.5" {
+		t.Errorf("expected: %q, actual: %q", "2.5", r.Config.Value("General", "Speed"))
+	}
+
+	writeFiles(t, dir, map[string]string{"main.ini": "Speed = 3\n"})
+	receiveWithin(t, errs)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected: %v, actual: %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,190 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrStopScan can be returned by the function given to Parser.Scan to stop
+// scanning without reporting an error.
+var ErrStopScan = errors.New("stop scan")
+
+// EventKind identifies the kind of an Event.
+type EventKind int
+
+const (
+	// SectionEvent is a section header.
+	SectionEvent EventKind = iota + 1
+	// EntryEvent is an assignment, an append, or the continuation of a
+	// multi-line value.
+	EntryEvent
+	// DirectiveEvent is a directive in square brackets, e.g., Require.
+	DirectiveEvent
+	// CommentEvent is a comment.
+	CommentEvent
+)
+
+// Position identifies a location in a file.
+type Position struct {
+	File   string // File path, empty for streams.
+	LineNr uint   // Line number.
+	Column int    // Byte offset in the line, starting at 1.
+}
+
+// String formats the position as "file:line:column".
+func (pos Position) String() string {
+	if pos.File == "" {
+		return fmt.Sprintf("%d:%d", pos.LineNr, pos.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", pos.File, pos.LineNr, pos.Column)
+}
+
+// Event is an element of an INI format stream reported by Parser.Scan.
+type Event struct {
+	Kind      EventKind // Kind of event.
+	Pos       Position  // Position of the element.
+	Section   string    // Section name, or section of entries.
+	Label     string    // Label of entries.
+	Value     string    // Value of entries, arguments of directives, or comment text.
+	Directive string    // Name of directives.
+	Append    bool      // Entry appends to the current value.
+	Continued bool      // Entry continues the value of the previous entry.
+}
+
+// Scan reads an INI format stream and calls fn for each section, entry,
+// directive and comment, in order. Directives are reported but not executed,
+// and no values are stored in Config. If fn returns an error, scanning stops
+// and the error is returned, unless it is ErrStopScan. The limits on line
+// length and total number of bytes apply.
+func (p *Parser) Scan(reader io.Reader, fn func(Event) error) error {
+	return p.scanReader(reader, "", fn)
+}
+
+// ScanFile is like Scan for the INI format file path.
+func (p *Parser) ScanFile(path string, fn func(Event) error) error {
+	file, err := hostFS{}.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return p.scanReader(file, path, fn)
+}
+
+// scanReader reads the lines of file path from reader and calls fn for each
+// element. The state of the last parse is not modified.
+func (p *Parser) scanReader(reader io.Reader, path string, fn func(Event) error) error {
+	var section, label string
+	var lineNr uint
+	var nrBytes int64
+
+	handle := func(line string, tok token) error {
+		ev := Event{Pos: Position{File: path, LineNr: lineNr}}
+
+		switch tok.kind {
+		case tokenSection:
+			name, args, ok := p.splitDirective(tok.name)
+			if ok {
+				ev.Kind = DirectiveEvent
+				ev.Directive = name
+				ev.Value = args
+			} else {
+				section = tok.name
+				ev.Kind = SectionEvent
+			}
+
+			ev.Section = section
+			ev.Pos.Column = tok.nameCol
+
+		case tokenAppend, tokenAssign:
+			label = tok.name
+			ev.Kind = EntryEvent
+			ev.Section = section
+			ev.Label = label
+			ev.Value = tok.value
+			ev.Append = tok.kind == tokenAppend
+			ev.Pos.Column = tok.nameCol
+
+		case tokenValue:
+			ev.Kind = EntryEvent
+			ev.Section = section
+			ev.Label = label
+			ev.Value = tok.text
+			ev.Append = true
+			ev.Continued = true
+			ev.Pos.Column = len(line) - len(trimLeftSpace(line)) + 1
+		}
+
+		if ev.Kind != 0 {
+			err := fn(ev)
+			if err != nil {
+				return err
+			}
+		}
+
+		if tok.commentCol != 0 {
+			ev = Event{
+				Kind:    CommentEvent,
+				Pos:     Position{File: path, LineNr: lineNr, Column: tok.commentCol},
+				Section: section,
+				Value:   tok.comment,
+			}
+
+			return fn(ev)
+		}
+
+		return nil
+	}
+
+	lines := p.newStreamReader(reader, nil)
+	lines.maxBytes = p.byteBudget(&nrBytes)
+
+	eof := false
+	for !eof {
+		res := lines.next()
+		line, err := res.line, res.err
+		if err != nil {
+			if err == ErrLineLength || err == ErrBytes {
+				return &LimitError{path, lineNr + 1, err}
+			}
+
+			if err != io.EOF {
+				return &ReadError{path, lineNr + 1, err}
+			}
+			eof = true
+		}
+
+		nrBytes += int64(len(line))
+		lineNr++
+		err = handle(line, res.tok)
+		if err == ErrStopScan {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
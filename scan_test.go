@@ -0,0 +1,183 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestScan(t *testing.T) {
+	input := "# Header\n" +
+		"[Section A] ; first\n" +
+		"Label A = Value A\n" +
+		"  continued\n" +
+		"Label B += Value B\n" +
+		"[Require other.ini]\n"
+
+	var events []Event
+
+	p := NewParser(nil)
+	err := p.Scan(strings.NewReader(input), func(ev Event) error {
+		events = append(events, ev)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Event{
+		{Kind: CommentEvent, Pos: Position{"", 1, 1}, Value: "Header"},
+		{Kind: SectionEvent, Pos: Position{"", 2, 2}, Section: "Section A"},
+		{Kind: CommentEvent, Pos: Position{"", 2, 13}, Section: "Section A", Value: "first"},
+		{Kind: EntryEvent, Pos: Position{"", 3, 1}, Section: "Section A", Label: "Label A",
+			Value: "Value A"},
+		{Kind: EntryEvent, Pos: Position{"", 4, 3}, Section: "Section A", Label: "Label A",
+			Value: "continued", Append: true, Continued: true},
+		{Kind: EntryEvent, Pos: Position{"", 5, 1}, Section: "Section A", Label: "Label B",
+			Value: "Value B", Append: true},
+		{Kind: DirectiveEvent, Pos: Position{"", 6, 2}, Section: "Section A",
+			Directive: "Require", Value: "other.ini"},
+	}
+
+	if !reflect.DeepEqual(expected, events) {
+		t.Errorf("\nexpected: %+v\nactual: %+v", expected, events)
+	}
+
+	if len(p.Config.Sections()) != 0 {
+		t.Errorf("unexpected configuration: %v", p.Config.Map())
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	var directives []string
+
+	p := NewParser(nil)
+	err := p.ScanFile("testdata/valid00.ini", func(ev Event) error {
+		if ev.Kind == DirectiveEvent {
+			directives = append(directives, ev.Pos.String()+" "+ev.Directive+" "+ev.Value)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Directives are not executed.
+	expected := []string{"testdata/valid00.ini:5:2 Include include00.ini"}
+	if !reflect.DeepEqual(expected, directives) {
+		t.Errorf("expected: %q, actual: %q", expected, directives)
+	}
+
+	err = p.ScanFile("testdata/__no_such_file", func(Event) error { return nil })
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestScanStop(t *testing.T) {
+	input := "[A]\nL = 1\n[B]\nL = 2\n[C]\nL = 3\n"
+
+	var values []string
+
+	p := NewParser(nil)
+	err := p.Scan(strings.NewReader(input), func(ev Event) error {
+		if ev.Kind == SectionEvent && ev.Section == "C" {
+			return ErrStopScan
+		}
+
+		if ev.Kind == EntryEvent && ev.Section == "B" {
+			values = append(values, ev.Value)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"2"}
+	if !reflect.DeepEqual(expected, values) {
+		t.Errorf("expected: %q, actual: %q", expected, values)
+	}
+}
+
+func TestScanError(t *testing.T) {
+	stop := errors.New("stop")
+
+	p := NewParser(nil)
+	err := p.Scan(strings.NewReader("[A]\n[B]\n"), func(ev Event) error {
+		return stop
+	})
+
+	if err != stop {
+		t.Errorf("expected: %v, actual: %v", stop, err)
+	}
+
+	p.Limits.MaxLineLength = 2
+	err = p.Scan(strings.NewReader("[A]\n"), func(ev Event) error {
+		return nil
+	})
+
+	if !errors.Is(err, ErrLineLength) {
+		t.Errorf("expected: %v, actual: %v", ErrLineLength, err)
+	}
+}
+
+func TestScanKeepsParseState(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.ini": newMapFile("[Require lib.ini]\n"),
+		"lib.ini":  newMapFile("[Lib]\nL0 = V0\n"),
+	}
+
+	p := NewParser(nil)
+	err := p.ParseFS(fsys, "main.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	revision := p.Config.Snapshot().Revision()
+
+	err = p.Scan(strings.NewReader("[S0]\nL0 = V0\n[Include other.ini]\n"), func(ev Event) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"main.ini", "lib.ini"}
+	if !reflect.DeepEqual(expected, p.Files()) {
+		t.Errorf("expected: %q, actual: %q", expected, p.Files())
+	}
+
+	if len(p.Resolutions()) != 1 {
+		t.Errorf("unexpected resolutions: %+v", p.Resolutions())
+	}
+
+	if p.Config.Snapshot().Revision() != revision {
+		t.Errorf("configuration modified: %v", p.Config.Map())
+	}
+}
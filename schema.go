@@ -0,0 +1,485 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type identifies the type of the values of a label.
+type Type int
+
+const (
+	// TypeString accepts any value.
+	TypeString Type = iota
+	// TypeBool accepts boolean values, see Config.Bool.
+	TypeBool
+	// TypeInt accepts integer values, see Config.Int.
+	TypeInt
+	// TypeFloat accepts floating point values, see Config.Float.
+	TypeFloat
+)
+
+// typeNames are the names of the types in schema files.
+var typeNames = [...]string{"string", "bool", "int", "float"}
+
+// String returns the name of the type.
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return "Type(" + strconv.Itoa(int(t)) + ")"
+	}
+
+	return typeNames[t]
+}
+
+// parseType returns the type with the given name.
+func parseType(name string) (Type, bool) {
+	for i, typeName := range typeNames {
+		if strings.EqualFold(name, typeName) {
+			return Type(i), true
+		}
+	}
+
+	return TypeString, false
+}
+
+// LabelSchema describes the valid values of a label.
+type LabelSchema struct {
+	Name        string   // Label.
+	Type        Type     // Type of the value, or of its elements if List is true.
+	List        bool     // Value is a comma-separated list.
+	Unit        string   // Unit of numeric values, see LabelSchema.Number.
+	Min         *float64 // Minimum of numeric values in Unit, nil if unbounded.
+	Max         *float64 // Maximum of numeric values in Unit, nil if unbounded.
+	Values      []string // Allowed values, empty to allow any.
+	Default     string   // Default value, empty for none, see ApplyDefaults.
+	Required    bool     // Label must be defined.
+	Description string   // Human readable description.
+}
+
+// SectionSchema describes a section and its labels.
+type SectionSchema struct {
+	Name        string         // Section name.
+	Required    bool           // Section must be defined.
+	Description string         // Human readable description.
+	Labels      []*LabelSchema // Labels of the section.
+}
+
+// Label returns the description of label name, or nil if not described.
+func (ss *SectionSchema) Label(name string) *LabelSchema {
+	for _, ls := range ss.Labels {
+		if ls.Name == name {
+			return ls
+		}
+	}
+
+	return nil
+}
+
+// Schema describes a valid configuration: which sections and labels exist
+// and which values they accept. A Schema is built in Go or loaded from an
+// INI format file with LoadSchema.
+type Schema struct {
+	Sections []*SectionSchema // Sections described.
+}
+
+// Section returns the description of section name, or nil if not
+// described.
+func (schema *Schema) Section(name string) *SectionSchema {
+	for _, ss := range schema.Sections {
+		if ss.Name == name {
+			return ss
+		}
+	}
+
+	return nil
+}
+
+// LoadSchema reads a schema from an INI format file, see ReadSchema.
+func LoadSchema(path string) (*Schema, error) {
+	p := NewParser(nil)
+	err := p.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaFromConfig(p.Config)
+}
+
+// ReadSchema reads a schema from an INI format stream. Each section of the
+// stream describes the section with the same name, with optional labels
+// Required and Description. Labels are described by sections named
+// "Section/Label", with the following labels, all optional:
+//
+//	Type        = string, bool, int or float
+//	List        = true if the value is a comma-separated list
+//	Unit        = unit of numeric values
+//	Min         = minimum of numeric values
+//	Max         = maximum of numeric values
+//	Values      = comma-separated list of allowed values
+//	Default     = default value
+//	Required    = true if the label must be defined
+//	Description = human readable description
+//
+// Sections and labels are sorted by name.
+func ReadSchema(reader io.Reader) (*Schema, error) {
+	p := NewParser(nil)
+	err := p.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return schemaFromConfig(p.Config)
+}
+
+// schemaFromConfig builds a schema from its description in c.
+func schemaFromConfig(c *Config) (*Schema, error) {
+	snap := c.Snapshot()
+	schema := new(Schema)
+
+	names := snap.Sections()
+	sort.Strings(names)
+
+	for _, name := range names {
+		section, label, isLabel := strings.Cut(name, "/")
+
+		ss := schema.Section(section)
+		if ss == nil {
+			ss = &SectionSchema{Name: section}
+			schema.Sections = append(schema.Sections, ss)
+		}
+
+		var err error
+		if isLabel {
+			ls := &LabelSchema{Name: label}
+			ss.Labels = append(ss.Labels, ls)
+			err = ls.load(snap, name)
+		} else {
+			err = ss.load(snap, name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return schema, nil
+}
+
+// schemaError returns an error for attribute l of section s.
+func schemaError(snap *Snapshot, s string, l string, msg string) error {
+	source := snap.Source(s, l)
+	if source == "" {
+		return fmt.Errorf("%s: %s: %s", s, l, msg)
+	}
+
+	return fmt.Errorf("%s: %s", source, msg)
+}
+
+// loadBool converts attribute l of section s to a boolean.
+func loadBool(snap *Snapshot, s string, l string) (bool, error) {
+	result, err := snap.Bool(s, l)
+	if err != nil {
+		return false, schemaError(snap, s, l, "invalid "+l+" attribute: "+snap.Value(s, l))
+	}
+
+	return result, nil
+}
+
+// loadFloat converts attribute l of section s to a floating point number.
+func loadFloat(snap *Snapshot, s string, l string) (*float64, error) {
+	result, err := snap.Float(s, l)
+	if err != nil {
+		return nil, schemaError(snap, s, l, "invalid "+l+" attribute: "+snap.Value(s, l))
+	}
+
+	return &result, nil
+}
+
+// splitList splits a comma-separated list.
+func splitList(value string) []string {
+	elems := strings.Split(value, ",")
+	for i := range elems {
+		elems[i] = strings.TrimSpace(elems[i])
+	}
+
+	return elems
+}
+
+// load reads the description of the section from section s of snap.
+func (ss *SectionSchema) load(snap *Snapshot, s string) error {
+	for _, l := range snap.Labels(s) {
+		var err error
+		switch l {
+		case "Required":
+			ss.Required, err = loadBool(snap, s, l)
+		case "Description":
+			ss.Description = snap.Value(s, l)
+		default:
+			err = schemaError(snap, s, l, "unknown section attribute: "+l)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load reads the description of the label from section s of snap.
+func (ls *LabelSchema) load(snap *Snapshot, s string) error {
+	for _, l := range snap.Labels(s) {
+		value := strings.TrimSpace(snap.Value(s, l))
+
+		var err error
+		switch l {
+		case "Type":
+			var ok bool
+			ls.Type, ok = parseType(value)
+			if !ok {
+				err = schemaError(snap, s, l, "unknown type: "+value)
+			}
+		case "List":
+			ls.List, err = loadBool(snap, s, l)
+		case "Unit":
+			ls.Unit = value
+			_, err = parseUnit(value)
+			if err != nil {
+				err = schemaError(snap, s, l, err.Error())
+			}
+		case "Min":
+			ls.Min, err = loadFloat(snap, s, l)
+		case "Max":
+			ls.Max, err = loadFloat(snap, s, l)
+		case "Values":
+			ls.Values = splitList(value)
+		case "Default":
+			ls.Default = value
+		case "Required":
+			ls.Required, err = loadBool(snap, s, l)
+		case "Description":
+			ls.Description = value
+		default:
+			err = schemaError(snap, s, l, "unknown label attribute: "+l)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Violation describes a value of a configuration that does not match its
+// schema.
+type Violation struct {
+	Section     string   // Section name.
+	Label       string   // Label, empty for violations of sections.
+	Value       string   // Offending value.
+	Source      string   // Source of the value, see Config.Source.
+	Msg         string   // Description of the violation.
+	Suggestions []string // Similar known names, for unknown sections and labels.
+}
+
+// Error formats the violation to a human readable sentence.
+func (v *Violation) Error() string {
+	var b strings.Builder
+	if v.Source != "" {
+		b.WriteString(v.Source)
+		b.WriteString(": ")
+	}
+
+	b.WriteString(v.Section)
+	if v.Label != "" {
+		b.WriteString(": ")
+		b.WriteString(v.Label)
+	}
+
+	b.WriteString(": ")
+	b.WriteString(v.Msg)
+	return b.String()
+}
+
+// Validate checks configuration c against schema and returns all
+// violations found, in the order of the schema. Sections and labels not
+// described by the schema are not checked.
+func Validate(c *Config, schema *Schema) []*Violation {
+	return schema.validate(c.Snapshot())
+}
+
+func (schema *Schema) validate(snap *Snapshot) []*Violation {
+	var violations []*Violation
+
+	for _, ss := range schema.Sections {
+		_, exists := snap.cfg[ss.Name]
+		if !exists {
+			if ss.Required {
+				violations = append(violations, &Violation{Section: ss.Name, Msg: "missing required section"})
+			}
+
+			continue
+		}
+
+		for _, ls := range ss.Labels {
+			value, exists := snap.lookup(ss.Name, ls.Name)
+			if !exists {
+				if ls.Required {
+					violations = append(violations, &Violation{Section: ss.Name, Label: ls.Name,
+						Msg: "missing required label"})
+				}
+
+				continue
+			}
+
+			msg := ls.check(value)
+			if msg != "" {
+				violations = append(violations, &Violation{Section: ss.Name, Label: ls.Name,
+					Value: value, Source: snap.Source(ss.Name, ls.Name), Msg: msg})
+			}
+		}
+	}
+
+	return violations
+}
+
+// check returns the description of the violation of value, or an empty
+// string if value is valid.
+func (ls *LabelSchema) check(value string) string {
+	if !ls.List {
+		return ls.checkElem(value)
+	}
+
+	for _, elem := range splitList(value) {
+		msg := ls.checkElem(elem)
+		if msg != "" {
+			return msg
+		}
+	}
+
+	return ""
+}
+
+// unit returns the unit of numeric values.
+func (ls *LabelSchema) unit() (unit, error) {
+	return parseUnit(ls.Unit)
+}
+
+// Number converts a numeric value of the label, or an element of a list, to
+// a number in the unit of the label. Values may be plain numbers, which are
+// in the unit of the label, or numbers followed by a unit of the same
+// dimension, e.g., "20 km/h" if the unit of the label is "m/s". Values of
+// int labels must be integers in the unit of the label.
+func (ls *LabelSchema) Number(elem string) (float64, error) {
+	elem = strings.TrimSpace(elem)
+
+	if ls.Unit == "" {
+		if ls.Type == TypeInt {
+			n, err := parseInt(elem)
+			return float64(n), unwrapNumError(err)
+		}
+
+		n, err := parseFloat(elem)
+		return n, unwrapNumError(err)
+	}
+
+	if ls.Type == TypeInt {
+		n, err := parseInt(elem)
+		if err == nil {
+			return float64(n), nil
+		}
+	}
+
+	u, err := ls.unit()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := parseQuantity(elem, u, ls.Unit)
+	if err != nil {
+		return 0, err
+	}
+
+	n /= u.scale
+	if ls.Type == TypeInt && !isInteger(n) {
+		return 0, fmt.Errorf("not an integer in %s", ls.Unit)
+	}
+
+	return n, nil
+}
+
+// isInteger tests if n is an integer, tolerating the rounding errors of
+// floating point arithmetic.
+func isInteger(n float64) bool {
+	rounded := math.Round(n)
+	return math.Abs(n-rounded) <= 1e-9*math.Max(1, math.Abs(n)) && math.Abs(rounded) <= math.MaxInt64
+}
+
+// checkElem returns the description of the violation of a single value, or
+// an empty string if elem is valid.
+func (ls *LabelSchema) checkElem(elem string) string {
+	elem = strings.TrimSpace(elem)
+
+	switch ls.Type {
+	case TypeBool:
+		_, err := parseBool(elem)
+		if err != nil {
+			return "expected bool: " + elem
+		}
+	case TypeInt, TypeFloat:
+		number, err := ls.Number(elem)
+		if errors.Is(err, ErrUnit) {
+			return elem + ": " + err.Error()
+		} else if err != nil {
+			return "expected " + ls.Type.String() + ": " + elem
+		}
+
+		if ls.Min != nil && number < *ls.Min {
+			return fmt.Sprintf("%s below minimum %g%s", elem, *ls.Min, ls.unitSuffix())
+		}
+
+		if ls.Max != nil && number > *ls.Max {
+			return fmt.Sprintf("%s above maximum %g%s", elem, *ls.Max, ls.unitSuffix())
+		}
+	}
+
+	if len(ls.Values) > 0 && !slices.Contains(ls.Values, elem) {
+		return fmt.Sprintf("%s not one of %s", elem, strings.Join(ls.Values, ", "))
+	}
+
+	return ""
+}
+
+// unitSuffix returns the unit of the label preceded by a space, or an
+// empty string if it has no unit.
+func (ls *LabelSchema) unitSuffix() string {
+	if ls.Unit == "" {
+		return ""
+	}
+
+	return " " + ls.Unit
+}
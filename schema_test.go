@@ -0,0 +1,159 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestLoadSchema(t *testing.T) {
+	schema, err := LoadSchema("testdata/schema.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &Schema{
+		Sections: []*SectionSchema{
+			{
+				Name:        "General",
+				Required:    true,
+				Description: "Vehicle-wide settings",
+				Labels: []*LabelSchema{
+					{Name: "Gains", Type: TypeFloat, List: true, Min: float(0)},
+					{Name: "Profile", Values: []string{"Hardware", "Simulation"}},
+					{Name: "Speed", Type: TypeFloat, Unit: "m/s", Min: float(0), Max: float(5), Default: "1.5"},
+					{Name: "Vehicle", Required: true},
+				},
+			},
+			{
+				Name: "Navigation",
+				Labels: []*LabelSchema{
+					{Name: "Enabled", Type: TypeBool, Required: true},
+					{Name: "Retries", Type: TypeInt, Max: float(10)},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(expected, schema) {
+		t.Errorf("expected: %v, actual: %v", expected, schema)
+	}
+
+	if schema.Section("General").Label("Speed").Unit != "m/s" || schema.Section("Other") != nil {
+		t.Errorf("unexpected lookup results")
+	}
+}
+
+func TestReadSchemaErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[S0/L0]\nType = double\n", "2: unknown type: double"},
+		{"[S0/L0]\nMin = zero\n", "2: invalid Min attribute: zero"},
+		{"[S0/L0]\nRequired = maybe\n", "2: invalid Required attribute: maybe"},
+		{"[S0/L0]\nDefault = 1\nMinimum = 0\n", "3: unknown label attribute: Minimum"},
+		{"[S0]\nOptional = true\n", "2: unknown section attribute: Optional"},
+	}
+
+	for _, test := range tests {
+		_, err := ReadSchema(strings.NewReader(test.input))
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := LoadSchema("testdata/schema.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := NewConfig()
+	err = NewParser(c).ParseFile("testdata/vehicle.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"testdata/vehicle.ini:5: General: Gains: -2 below minimum 0",
+		"testdata/vehicle.ini:4: General: Profile: Field not one of Hardware, Simulation",
+		"testdata/vehicle.ini:3: General: Speed: 7.5 above maximum 5 m/s",
+		"Navigation: Enabled: missing required label",
+		"testdata/vehicle.ini:8: Navigation: Retries: expected int: ten",
+	}
+
+	var actual []string
+	for _, v := range Validate(c, schema) {
+		actual = append(actual, v.Error())
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	c.SetMap(map[string]map[string]string{
+		"General": {"Vehicle": "lauv-noptilus-1", "Speed": "1", "Gains": "0, 1.5"},
+	})
+
+	violations := Validate(c, schema)
+	if len(violations) != 0 {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+
+	c.SetMap(nil)
+	violations = Validate(c, schema)
+	if len(violations) != 1 || violations[0].Error() != "General: missing required section" {
+		t.Errorf("unexpected violations: %v", violations)
+	}
+}
+
+func TestSchemaInGo(t *testing.T) {
+	schema := &Schema{
+		Sections: []*SectionSchema{{
+			Name: "Control",
+			Labels: []*LabelSchema{
+				{Name: "Mode", Values: []string{"Depth", "Altitude"}, Required: true},
+				{Name: "Frequency", Type: TypeInt, Min: float(1), Unit: "Hz"},
+			},
+		}},
+	}
+
+	c := NewConfig()
+	c.Update(func(tx *Tx) error {
+		tx.SetSource("operator")
+		tx.SetValue("Control", "Mode", "Pitch")
+		tx.SetValue("Control", "Frequency", "0x10")
+		return nil
+	})
+
+	violations := Validate(c, schema)
+	expected := &Violation{Section: "Control", Label: "Mode", Value: "Pitch", Source: "operator",
+		Msg: "Pitch not one of Depth, Altitude"}
+	if len(violations) != 1 || !reflect.DeepEqual(expected, violations[0]) {
+		t.Errorf("expected: %v, actual: %v", expected, violations)
+	}
+}
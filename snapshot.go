@@ -0,0 +1,84 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+// Snapshot is an immutable view of the contents of a Config at a point in
+// time. Reading a Snapshot requires no locking.
+type Snapshot struct {
+	cfg      map[string]map[string]string // Contents.
+	src      map[string]map[string]string // Source of each value.
+	revision uint64                       // Revision number.
+	tracker  *accessTracker               // Recorder of reads, nil if disabled.
+	exprs    bool                         // Numeric getters evaluate expressions.
+}
+
+// emptySnapshot is the snapshot of an empty configuration.
+var emptySnapshot = &Snapshot{
+	cfg: make(map[string]map[string]string),
+	src: make(map[string]map[string]string),
+}
+
+func (snap *Snapshot) lookup(s string, l string) (string, bool) {
+	value, exists := snap.cfg[s][l]
+	return value, exists
+}
+
+// Revision returns the revision number of the snapshot. The number is
+// incremented by each committed modification of a Config, starting at zero.
+func (snap *Snapshot) Revision() uint64 {
+	return snap.revision
+}
+
+// Map returns a copy of the snapshot contents.
+func (snap *Snapshot) Map() map[string]map[string]string {
+	return cloneTable(snap.cfg)
+}
+
+// Sections returns an unordered slice of all section names.
+func (snap *Snapshot) Sections() []string {
+	var sections []string
+	for section := range snap.cfg {
+		sections = append(sections, section)
+	}
+
+	return sections
+}
+
+// Labels returns an unordered slice of all labels of a section s.
+func (snap *Snapshot) Labels(s string) []string {
+	var labels []string
+	for label := range snap.cfg[s] {
+		labels = append(labels, label)
+	}
+
+	return labels
+}
+
+// Value retrieves the value of label l of section s.
+func (snap *Snapshot) Value(s string, l string) string {
+	snap.track(s, l)
+	return snap.cfg[s][l]
+}
+
+// Source returns the source of the value of label l of section s, e.g.,
+// "file.ini:12" for values read by a Parser, or the source set with
+// Tx.SetSource. An empty string is returned if the source is unknown.
+func (snap *Snapshot) Source(s string, l string) string {
+	return snap.src[s][l]
+}
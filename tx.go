@@ -0,0 +1,344 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+// Tx is a set of modifications of a Config, applied atomically by
+// Config.Update. Reads through a Tx observe the modifications made so far.
+// Contents shared with the snapshot the transaction started from are copied
+// on write.
+type Tx struct {
+	base    *Snapshot                    // Snapshot the transaction started from.
+	cfg     map[string]map[string]string // Contents.
+	src     map[string]map[string]string // Source of each value.
+	shared  bool                         // Maps cfg and src are shared with base.
+	owned   map[string]bool              // Sections of cfg and src not shared with base.
+	ops     []txOp                       // Operations, in order.
+	source  string                       // Source of modifications.
+	touched map[valueKey]string          // Source of modified values.
+	order   []valueKey                   // Modified values, in order.
+}
+
+// txOp is an operation of a transaction.
+type txOp struct {
+	source string       // Source of the modification.
+	fn     func(tx *Tx) // Modification.
+}
+
+// valueKey identifies a value.
+type valueKey struct {
+	section string // Section name.
+	label   string // Label.
+}
+
+func newTx(base *Snapshot) *Tx {
+	return &Tx{
+		base:   base,
+		cfg:    base.cfg,
+		src:    base.src,
+		shared: true,
+		owned:  make(map[string]bool),
+	}
+}
+
+// do applies operation fn and records it.
+func (tx *Tx) do(fn func(tx *Tx)) {
+	fn(tx)
+	tx.ops = append(tx.ops, txOp{tx.source, fn})
+}
+
+// rebase returns a transaction with the operations of tx applied on
+// snapshot base.
+func (tx *Tx) rebase(base *Snapshot) *Tx {
+	newTx := newTx(base)
+	for _, op := range tx.ops {
+		newTx.source = op.source
+		newTx.do(op.fn)
+	}
+
+	newTx.source = tx.source
+	return newTx
+}
+
+// snapshot returns the contents of the transaction as revision rev. The
+// transaction must not be modified afterwards.
+func (tx *Tx) snapshot(rev uint64) *Snapshot {
+	return &Snapshot{cfg: tx.cfg, src: tx.src, revision: rev}
+}
+
+// touch records the modification of label l of section s.
+func (tx *Tx) touch(s string, l string) {
+	if tx.touched == nil {
+		tx.touched = make(map[valueKey]string)
+	}
+
+	key := valueKey{s, l}
+	if _, exists := tx.touched[key]; !exists {
+		tx.order = append(tx.order, key)
+	}
+
+	tx.touched[key] = tx.source
+}
+
+// touchSection records the modification of all labels of section s.
+func (tx *Tx) touchSection(s string, m map[string]string) {
+	for l := range m {
+		tx.touch(s, l)
+	}
+}
+
+// changes returns the values that differ from the base snapshot, in order
+// of modification.
+func (tx *Tx) changes() []Change {
+	var changes []Change
+	for _, key := range tx.order {
+		oldValue, oldExists := tx.base.lookup(key.section, key.label)
+		newValue, newExists := tx.lookup(key.section, key.label)
+
+		change := Change{
+			Section: key.section,
+			Label:   key.label,
+			Old:     oldValue,
+			New:     newValue,
+			Source:  tx.touched[key],
+		}
+
+		switch {
+		case !oldExists && !newExists:
+			continue
+		case !oldExists:
+			change.Kind = ValueAdded
+		case !newExists:
+			change.Kind = ValueRemoved
+		case oldValue != newValue:
+			change.Kind = ValueModified
+		default:
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func (tx *Tx) lookup(s string, l string) (string, bool) {
+	value, exists := tx.cfg[s][l]
+	return value, exists
+}
+
+// unshare copies the map of sections if shared with the base snapshot.
+func (tx *Tx) unshare() {
+	if !tx.shared {
+		return
+	}
+
+	newCfg := make(map[string]map[string]string, len(tx.cfg)+1)
+	for key, value := range tx.cfg {
+		newCfg[key] = value
+	}
+
+	newSrc := make(map[string]map[string]string, len(tx.cfg)+1)
+	for key, value := range tx.src {
+		newSrc[key] = value
+	}
+
+	tx.cfg = newCfg
+	tx.src = newSrc
+	tx.shared = false
+}
+
+// section makes section s writable, creating it if needed.
+func (tx *Tx) section(s string) {
+	tx.unshare()
+
+	if !tx.owned[s] {
+		tx.cfg[s] = cloneMap(tx.cfg[s])
+		tx.src[s] = cloneMap(tx.src[s])
+		tx.owned[s] = true
+	}
+}
+
+// sourceMap returns a map assigning the current source to the labels of m.
+func (tx *Tx) sourceMap(m map[string]string) map[string]string {
+	src := make(map[string]string, len(m))
+	for l := range m {
+		src[l] = tx.source
+	}
+
+	return src
+}
+
+// setTables replaces the contents and the sources of the values with copies
+// of cfg and src.
+func (tx *Tx) setTables(cfg map[string]map[string]string, src map[string]map[string]string) {
+	for s, section := range tx.cfg {
+		tx.touchSection(s, section)
+	}
+
+	for s, section := range cfg {
+		tx.touchSection(s, section)
+	}
+
+	tx.cfg = cloneTable(cfg)
+	tx.src = cloneTable(src)
+	tx.shared = false
+	tx.owned = make(map[string]bool)
+	for s := range tx.cfg {
+		tx.owned[s] = true
+		if tx.src[s] == nil {
+			tx.src[s] = make(map[string]string)
+		}
+	}
+}
+
+// restore replaces the contents and the sources of the values with those
+// of snapshot snap. The changes are reported with the current source.
+func (tx *Tx) restore(snap *Snapshot) {
+	tx.setTables(snap.cfg, snap.src)
+}
+
+func (tx *Tx) setMap(m map[string]map[string]string) {
+	src := make(map[string]map[string]string, len(m))
+	for s, section := range m {
+		src[s] = tx.sourceMap(section)
+	}
+
+	tx.setTables(m, src)
+}
+
+func (tx *Tx) setSection(s string, m map[string]string) {
+	tx.touchSection(s, tx.cfg[s])
+	tx.touchSection(s, m)
+	tx.section(s)
+	tx.cfg[s] = cloneMap(m)
+	tx.src[s] = tx.sourceMap(m)
+}
+
+func (tx *Tx) setValue(s string, l string, value string) {
+	tx.touch(s, l)
+	tx.section(s)
+	tx.cfg[s][l] = value
+	tx.src[s][l] = tx.source
+}
+
+func (tx *Tx) appendValue(s string, l string, value string, sep string) {
+	curValue := tx.cfg[s][l]
+	if curValue == "" {
+		tx.setValue(s, l, value)
+	} else {
+		tx.setValue(s, l, curValue+sep+value)
+	}
+}
+
+func (tx *Tx) remove(s string, l string) {
+	_, exists := tx.cfg[s][l]
+	if exists {
+		tx.touch(s, l)
+		tx.section(s)
+		delete(tx.cfg[s], l)
+		delete(tx.src[s], l)
+	}
+}
+
+func (tx *Tx) removeSection(s string) {
+	_, exists := tx.cfg[s]
+	if exists {
+		tx.touchSection(s, tx.cfg[s])
+		tx.unshare()
+		delete(tx.cfg, s)
+		delete(tx.src, s)
+		delete(tx.owned, s)
+	}
+}
+
+// SetSource sets the source of subsequent modifications, e.g., the name of
+// the component making them. The source is reported in the changes
+// delivered to subscribers and stored as the source of the values
+// modified, see Config.Source.
+func (tx *Tx) SetSource(source string) {
+	tx.source = source
+}
+
+// Snapshot returns the contents of the transaction so far.
+func (tx *Tx) Snapshot() *Snapshot {
+	return &Snapshot{cfg: cloneTable(tx.cfg), src: cloneTable(tx.src)}
+}
+
+// Sections returns an unordered slice of all section names.
+func (tx *Tx) Sections() []string {
+	return (&Snapshot{cfg: tx.cfg}).Sections()
+}
+
+// Labels returns an unordered slice of all labels of a section s.
+func (tx *Tx) Labels(s string) []string {
+	return (&Snapshot{cfg: tx.cfg}).Labels(s)
+}
+
+// Value retrieves the value of label l of section s.
+func (tx *Tx) Value(s string, l string) string {
+	return tx.cfg[s][l]
+}
+
+// Source returns the source of the value of label l of section s.
+func (tx *Tx) Source(s string, l string) string {
+	return tx.src[s][l]
+}
+
+// SetMap replaces the contents of the configuration with a copy of map m.
+func (tx *Tx) SetMap(m map[string]map[string]string) {
+	tx.do(func(tx *Tx) {
+		tx.setMap(m)
+	})
+}
+
+// SetSection replaces the contents of section s with a copy of map m.
+func (tx *Tx) SetSection(s string, m map[string]string) {
+	tx.do(func(tx *Tx) {
+		tx.setSection(s, m)
+	})
+}
+
+// SetValue assigns value to label l of section s.
+func (tx *Tx) SetValue(s string, l string, value string) {
+	tx.do(func(tx *Tx) {
+		tx.setValue(s, l, value)
+	})
+}
+
+// AppendValue appends value to label l of section s, see
+// Config.AppendValue.
+func (tx *Tx) AppendValue(s string, l string, value string, sep string) {
+	tx.do(func(tx *Tx) {
+		tx.appendValue(s, l, value, sep)
+	})
+}
+
+// Remove removes label l of section s.
+func (tx *Tx) Remove(s string, l string) {
+	tx.do(func(tx *Tx) {
+		tx.remove(s, l)
+	})
+}
+
+// RemoveSection removes section s and all its labels.
+func (tx *Tx) RemoveSection(s string) {
+	tx.do(func(tx *Tx) {
+		tx.removeSection(s)
+	})
+}
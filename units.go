@@ -0,0 +1,328 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnit is wrapped by the errors of values with a unit of the wrong
+// dimension.
+var ErrUnit = errors.New("incompatible unit")
+
+// Base dimensions. Angles are treated as a base dimension, unlike in SI, so
+// that degrees and radians are not mistaken for plain numbers.
+const (
+	dimLength = iota
+	dimMass
+	dimTime
+	dimCurrent
+	dimTemperature
+	dimAmount
+	dimAngle
+	nrDims
+)
+
+// dimension holds the exponents of the base dimensions of a quantity.
+type dimension [nrDims]int8
+
+// unit is a unit of measurement.
+type unit struct {
+	scale      float64   // Value of the unit in SI units.
+	dim        dimension // Dimension.
+	prefixable bool      // Accepts SI prefixes.
+}
+
+// mul returns the product of unit u with unit v raised to exp.
+func (u unit) mul(v unit, exp int) unit {
+	u.scale *= math.Pow(v.scale, float64(exp))
+	for i := range u.dim {
+		u.dim[i] += v.dim[i] * int8(exp)
+	}
+
+	return u
+}
+
+// dimensionless is the unit of plain numbers.
+var dimensionless = unit{scale: 1}
+
+func baseUnit(dim int, scale float64) unit {
+	var u unit
+	u.scale = scale
+	u.dim[dim] = 1
+	return u
+}
+
+var (
+	metre    = baseUnit(dimLength, 1)
+	kilogram = baseUnit(dimMass, 1)
+	second   = baseUnit(dimTime, 1)
+	radian   = baseUnit(dimAngle, 1)
+	newton   = kilogram.mul(metre, 1).mul(second, -2)
+	joule    = newton.mul(metre, 1)
+	watt     = joule.mul(second, -1)
+	ampere   = baseUnit(dimCurrent, 1)
+)
+
+// prefixable returns unit u accepting SI prefixes.
+func prefixable(u unit) unit {
+	u.prefixable = true
+	return u
+}
+
+// units are the recognized units by symbol.
+var units = map[string]unit{
+	"m":   prefixable(metre),
+	"g":   prefixable(baseUnit(dimMass, 1e-3)),
+	"s":   prefixable(second),
+	"A":   prefixable(ampere),
+	"K":   prefixable(baseUnit(dimTemperature, 1)),
+	"mol": prefixable(baseUnit(dimAmount, 1)),
+	"rad": prefixable(radian),
+	"Hz":  prefixable(dimensionless.mul(second, -1)),
+	"N":   prefixable(newton),
+	"Pa":  prefixable(newton.mul(metre, -2)),
+	"J":   prefixable(joule),
+	"W":   prefixable(watt),
+	"V":   prefixable(watt.mul(ampere, -1)),
+	"bar": prefixable(newton.mul(metre, -2).mul(unit{scale: 1e5}, 1)),
+	"deg": baseUnit(dimAngle, math.Pi/180),
+	"°":   baseUnit(dimAngle, math.Pi/180),
+	"min": baseUnit(dimTime, 60),
+	"h":   baseUnit(dimTime, 3600),
+	"kn":  metre.mul(second, -1).mul(unit{scale: 1852.0 / 3600}, 1),
+	"rpm": radian.mul(second, -1).mul(unit{scale: 2 * math.Pi / 60}, 1),
+	"%":   {scale: 0.01},
+}
+
+// prefixes are the recognized SI prefixes.
+var prefixes = map[string]float64{
+	"G": 1e9,
+	"M": 1e6,
+	"k": 1e3,
+	"c": 1e-2,
+	"m": 1e-3,
+	"u": 1e-6,
+	"µ": 1e-6,
+	"n": 1e-9,
+}
+
+// lookupUnit returns the unit with the given symbol, optionally prefixed.
+func lookupUnit(symbol string) (unit, bool) {
+	u, ok := units[symbol]
+	if ok {
+		return u, true
+	}
+
+	_, size := utf8.DecodeRuneInString(symbol)
+	scale, ok := prefixes[symbol[:size]]
+	if !ok {
+		return unit{}, false
+	}
+
+	u, ok = units[symbol[size:]]
+	if !ok || !u.prefixable {
+		return unit{}, false
+	}
+
+	u.scale *= scale
+	return u, true
+}
+
+// isUnitSeparator tests if r separates the factors of a unit.
+func isUnitSeparator(r rune) bool {
+	return r == ' ' || r == '*' || r == '·' || r == '/'
+}
+
+// parseUnit parses a unit such as "m/s", "km/h", "kg·m/s^2", "m/s2" or "1/s". Each
+// "/" divides by the factor that follows it.
+func parseUnit(text string) (unit, error) {
+	result := dimensionless
+	rest := strings.TrimSpace(text)
+	if rest == "" {
+		return result, nil
+	}
+
+	first, _ := utf8.DecodeRuneInString(rest)
+	if isUnitSeparator(first) {
+		return unit{}, fmt.Errorf("invalid unit %q", text)
+	}
+
+	exp := 1
+	for rest != "" {
+		r, size := utf8.DecodeRuneInString(rest)
+		if isUnitSeparator(r) {
+			if r == '/' {
+				exp = -1
+			}
+
+			rest = rest[size:]
+			continue
+		}
+
+		// Numerator of reciprocal units, e.g., "1/s".
+		if r == '1' && (len(rest) == 1 || isUnitSeparator(rune(rest[1]))) {
+			rest = rest[1:]
+			continue
+		}
+
+		// Symbol, up to an exponent or a separator.
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return isUnitSeparator(r) || r == '^' || r == '-' || (r >= '0' && r <= '9')
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+
+		symbol := rest[:end]
+		rest = rest[end:]
+
+		u, ok := lookupUnit(symbol)
+		if !ok {
+			return unit{}, fmt.Errorf("unknown unit %q", symbol)
+		}
+
+		// Exponent.
+		rest = strings.TrimPrefix(rest, "^")
+		end = strings.IndexFunc(rest, func(r rune) bool {
+			return !(r >= '0' && r <= '9' || r == '-')
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+
+		power := 1
+		if end > 0 {
+			var err error
+			power, err = strconv.Atoi(rest[:end])
+			if err != nil {
+				return unit{}, fmt.Errorf("invalid exponent %q", rest[:end])
+			}
+
+			rest = rest[end:]
+		}
+
+		result = result.mul(u, exp*power)
+		exp = 1
+	}
+
+	return result, nil
+}
+
+// splitQuantity splits value into a number and a unit.
+func splitQuantity(value string) (string, string) {
+	value = strings.TrimSpace(value)
+
+	i := 0
+	if i < len(value) && (value[i] == '+' || value[i] == '-') {
+		i++
+	}
+
+	for i < len(value) {
+		c := value[i]
+		if isDigit(c) || c == '.' {
+			i++
+		} else if (c == 'e' || c == 'E') && i+1 < len(value) &&
+			(isDigit(value[i+1]) || value[i+1] == '+' || value[i+1] == '-') {
+			i += 2
+		} else {
+			break
+		}
+	}
+
+	return value[:i], strings.TrimSpace(value[i:])
+}
+
+// parseMeasure converts value, a number optionally followed by a unit, to
+// SI units, and returns it with its unit. Numbers without a unit are in
+// unit def.
+func parseMeasure(value string, def unit) (float64, unit, error) {
+	number, symbol := splitQuantity(value)
+
+	result, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, unit{}, unwrapNumError(err)
+	}
+
+	if symbol == "" {
+		return result * def.scale, def, nil
+	}
+
+	u, err := parseUnit(symbol)
+	if err != nil {
+		return 0, unit{}, err
+	}
+
+	return result * u.scale, u, nil
+}
+
+// parseQuantity is like parseMeasure but fails if the unit of value does not
+// have the dimension of def, which is named defName in errors.
+func parseQuantity(value string, def unit, defName string) (float64, error) {
+	result, u, err := parseMeasure(value, def)
+	if err != nil {
+		return 0, err
+	}
+
+	if u.dim != def.dim {
+		_, symbol := splitQuantity(value)
+		if defName == "" {
+			return 0, fmt.Errorf("%w %q, expected a plain number", ErrUnit, symbol)
+		}
+
+		return 0, fmt.Errorf("%w %q, expected %q", ErrUnit, symbol, defName)
+	}
+
+	return result, nil
+}
+
+// Quantity retrieves the value of label l of section s, a number optionally
+// followed by a unit, e.g., "2.5 m/s", "90 deg", "10 Hz" or "500 ms", and
+// converts it to SI units (angles in radians). Numbers without a unit are in
+// unit defUnit. An error wrapping ErrUnit is returned if the unit of the
+// value has a different dimension. See Config.SetExpressions for the
+// evaluation of expressions, which are in unit defUnit.
+func (snap *Snapshot) Quantity(s string, l string, defUnit string) (float64, error) {
+	def, err := parseUnit(defUnit)
+	if err != nil {
+		return 0, err
+	}
+
+	return convert(snap, s, l, func(value string) (float64, error) {
+		// Values with the syntax of a quantity are not expressions.
+		_, _, err := parseMeasure(value, def)
+		if err == nil || !snap.exprs {
+			return parseQuantity(value, def, defUnit)
+		}
+
+		number, err := snap.eval(s, l, value, &evalUnit{def, defUnit}, nil)
+		return number * def.scale, err
+	})
+}
+
+// Quantity retrieves the value of label l of section s converted to SI
+// units, see Snapshot.Quantity.
+func (c *Config) Quantity(s string, l string, defUnit string) (float64, error) {
+	return c.Snapshot().Quantity(s, l, defUnit)
+}
@@ -0,0 +1,192 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuantity(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{
+		"S0": {
+			"Speed":     "2.5 m/s",
+			"Knots":     "10 kn",
+			"Road":      "36 km/h",
+			"Plain":     "1.5",
+			"Angle":     "90 deg",
+			"Degrees":   "-45°",
+			"Radians":   "0.5 rad",
+			"Frequency": "10 Hz",
+			"Period":    "500 ms",
+			"Minutes":   "2 min",
+			"Accel":     "9.8 m/s^2",
+			"Accel2":    "9.8 m/s2",
+			"Accel3":    "9.8 m/s/s",
+			"Area":      "2 cm^2",
+			"Pressure":  "1.5 bar",
+			"Force":     "3 kg·m/s^2",
+			"Rate":      "60 rpm",
+			"Micro":     "5 µs",
+			"Ratio":     "50 %",
+			"Exp":       "1e3 mm",
+		},
+	})
+
+	tests := []struct {
+		label    string
+		unit     string
+		expected float64
+	}{
+		{"Speed", "m/s", 2.5},
+		{"Knots", "m/s", 5.144444444444445},
+		{"Road", "m/s", 10},
+		{"Plain", "m/s", 1.5},
+		{"Plain", "km/h", 1.5 / 3.6},
+		{"Plain", "", 1.5},
+		{"Angle", "rad", math.Pi / 2},
+		{"Degrees", "deg", -math.Pi / 4},
+		{"Radians", "deg", 0.5},
+		{"Frequency", "Hz", 10},
+		{"Frequency", "1/s", 10},
+		{"Period", "s", 0.5},
+		{"Minutes", "s", 120},
+		{"Accel", "m/s^2", 9.8},
+		{"Accel2", "m/s^2", 9.8},
+		{"Accel3", "m s^-2", 9.8},
+		{"Area", "m^2", 2e-4},
+		{"Pressure", "Pa", 1.5e5},
+		{"Force", "N", 3},
+		{"Rate", "rad/s", 2 * math.Pi},
+		{"Micro", "s", 5e-6},
+		{"Ratio", "", 0.5},
+		{"Exp", "m", 1},
+	}
+
+	for _, test := range tests {
+		actual, err := c.Quantity("S0", test.label, test.unit)
+		if err != nil || math.Abs(actual-test.expected) > 1e-12*math.Abs(test.expected) {
+			t.Errorf("%s: expected: %v, actual: %v, %v", test.label, test.expected, actual, err)
+		}
+	}
+}
+
+func TestQuantityErrors(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{
+		"S0": {"Speed": "2.5 m/s", "Angle": "90 deg", "Bad": "2 furlongs", "Text": "fast"},
+	})
+
+	tests := []struct {
+		label    string
+		unit     string
+		unitErr  bool
+		expected string
+	}{
+		{"Speed", "s", true, `S0: Speed: invalid value "2.5 m/s": incompatible unit "m/s", expected "s"`},
+		{"Angle", "", true, `S0: Angle: invalid value "90 deg": incompatible unit "deg", expected a plain number`},
+		{"Bad", "m", false, `S0: Bad: invalid value "2 furlongs": unknown unit "furlongs"`},
+		{"Text", "m", false, `S0: Text: invalid value "fast": invalid syntax`},
+		{"Missing", "m", false, `S0: Missing: no value`},
+	}
+
+	for _, test := range tests {
+		_, err := c.Quantity("S0", test.label, test.unit)
+		if err == nil || err.Error() != test.expected || errors.Is(err, ErrUnit) != test.unitErr {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+	}
+
+	_, err := c.Quantity("S0", "Speed", "parsecs")
+	if err == nil || err.Error() != `unknown unit "parsecs"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSchemaUnits(t *testing.T) {
+	schema, err := ReadSchema(strings.NewReader(`
+[Control/Speed]
+Type    = float
+Unit    = m/s
+Max     = 5
+
+[Control/Period]
+Type    = int
+Unit    = ms
+Default = 100
+
+[Control/Timeout]
+Type    = float
+Unit    = s
+Default = 3 * Period
+
+[Control/Heading]
+Type    = float
+Unit    = deg
+Values  = 0, 90
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		values   map[string]string
+		expected []string
+	}{
+		{map[string]string{"Speed": "18 km/h", "Period": "0.25 s"}, nil},
+		{map[string]string{"Speed": "20 kn"}, []string{`Control: Speed: 20 kn above maximum 5 m/s`}},
+		{map[string]string{"Speed": "2 s"}, []string{`Control: Speed: 2 s: incompatible unit "s", expected "m/s"`}},
+		{map[string]string{"Period": "0.1 ms"}, []string{`Control: Period: expected int: 0.1 ms`}},
+		{map[string]string{"Heading": "90"}, nil},
+	}
+
+	for _, test := range tests {
+		c := NewConfig()
+		c.SetSection("Control", test.values)
+
+		var actual []string
+		for _, v := range Validate(c, schema) {
+			actual = append(actual, v.Error())
+		}
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("expected: %q, actual: %q", test.expected, actual)
+		}
+	}
+
+	c := NewConfig()
+	c.SetValue("Control", "Period", "0.5 s")
+	err = ApplyDefaults(c, schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.Value("Control", "Timeout") != "1.5" {
+		t.Errorf("expected: %q, actual: %q", "1.5", c.Value("Control", "Timeout"))
+	}
+
+	_, err = ReadSchema(strings.NewReader("[S0/L0]\nUnit = m/fortnight\n"))
+	if err == nil || err.Error() != `2: unknown unit "fortnight"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"sort"
+	"strings"
+)
+
+// CheckUnknown reports the sections and labels of c that are not described
+// by schema, sorted by section and label. Each violation suggests the known
+// names most similar to the unknown one, if any is close enough to be a
+// likely misspelling.
+func CheckUnknown(c *Config, schema *Schema) []*Violation {
+	snap := c.Snapshot()
+
+	var sectionNames []string
+	for _, ss := range schema.Sections {
+		sectionNames = append(sectionNames, ss.Name)
+	}
+
+	sections := snap.Sections()
+	sort.Strings(sections)
+
+	var violations []*Violation
+	for _, s := range sections {
+		ss := schema.Section(s)
+		if ss == nil {
+			violations = append(violations, unknownViolation(s, "", "", "section", sectionNames))
+			continue
+		}
+
+		var labelNames []string
+		for _, ls := range ss.Labels {
+			labelNames = append(labelNames, ls.Name)
+		}
+
+		labels := snap.Labels(s)
+		sort.Strings(labels)
+
+		for _, l := range labels {
+			if ss.Label(l) == nil {
+				violations = append(violations, unknownViolation(s, l, snap.Source(s, l), "label", labelNames))
+			}
+		}
+	}
+
+	return violations
+}
+
+// unknownViolation returns a violation describing an unknown section or
+// label, with suggestions from the known names.
+func unknownViolation(s string, l string, source string, kind string, known []string) *Violation {
+	name := s
+	if l != "" {
+		name = l
+	}
+
+	v := &Violation{Section: s, Label: l, Source: source, Msg: "unknown " + kind}
+	v.Suggestions = suggest(name, known)
+	if len(v.Suggestions) > 0 {
+		v.Msg += ", did you mean " + quoteList(v.Suggestions) + "?"
+	}
+
+	return v
+}
+
+// quoteList formats names as `"a"`, `"a" or "b"`, or `"a", "b" or "c"`.
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = `"` + name + `"`
+	}
+
+	last := len(quoted) - 1
+	if last == 0 {
+		return quoted[0]
+	}
+
+	return strings.Join(quoted[:last], ", ") + " or " + quoted[last]
+}
+
+// suggest returns the names in known closest to name, sorted, if their
+// edit distance is at most a third of the length of name, and at least 1.
+// Case is ignored.
+func suggest(name string, known []string) []string {
+	target := []rune(strings.ToLower(name))
+	maxDist := max(1, len(target)/3)
+
+	var suggestions []string
+	for _, candidate := range known {
+		dist := editDistance(target, []rune(strings.ToLower(candidate)))
+		if dist > maxDist {
+			continue
+		}
+
+		if dist < maxDist {
+			maxDist = dist
+			suggestions = nil
+		}
+
+		suggestions = append(suggestions, candidate)
+	}
+
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+// editDistance returns the minimum number of insertions, deletions,
+// substitutions and transpositions of adjacent characters transforming a
+// into b (optimal string alignment distance).
+func editDistance(a []rune, b []rune) int {
+	// Rows i-2, i-1 and i of the distance matrix.
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
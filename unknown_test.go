@@ -0,0 +1,122 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"Max Speed", "Max Speed", 0},
+		{"Max Sped", "Max Speed", 1},
+		{"Max Spede", "Max Speed", 1},
+		{"Mxa Speed", "Max Speed", 1},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+		{"Profundidade", "Profundidade", 0},
+		{"ação", "acao", 2},
+	}
+
+	for _, test := range tests {
+		actual := editDistance([]rune(test.a), []rune(test.b))
+		if actual != test.expected {
+			t.Errorf("%q, %q: expected: %d, actual: %d", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	known := []string{"Max Speed", "Min Speed", "Speed", "Depth", "Timeout"}
+
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"Max Sped", []string{"Max Speed"}},
+		{"max speed", []string{"Max Speed"}},
+		{"Mix Speed", []string{"Max Speed", "Min Speed"}},
+		{"Sped", []string{"Speed"}},
+		{"Dept", []string{"Depth"}},
+		{"Altitude", nil},
+		{"X", nil},
+	}
+
+	for _, test := range tests {
+		actual := suggest(test.name, known)
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("%q: expected: %q, actual: %q", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	schema, err := LoadSchema("testdata/schema.ini")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := NewConfig()
+	err = NewParser(c).Parse(strings.NewReader(`[General]
+Vehicle = lauv-xplore-1
+Sped    = 2
+Gain    = 1, 2
+Color   = yellow
+
+[Navigatoin]
+Enabled = true
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		`5: General: Color: unknown label`,
+		`4: General: Gain: unknown label, did you mean "Gains"?`,
+		`3: General: Sped: unknown label, did you mean "Speed"?`,
+		`Navigatoin: unknown section, did you mean "Navigation"?`,
+	}
+
+	var actual []string
+	violations := CheckUnknown(c, schema)
+	for _, v := range violations {
+		actual = append(actual, v.Error())
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %q, actual: %q", expected, actual)
+	}
+
+	if !reflect.DeepEqual([]string{"Speed"}, violations[2].Suggestions) {
+		t.Errorf("expected: %q, actual: %q", []string{"Speed"}, violations[2].Suggestions)
+	}
+
+	if quoteList([]string{"a", "b", "c"}) != `"a", "b" or "c"` {
+		t.Errorf("unexpected list: %s", quoteList([]string{"a", "b", "c"}))
+	}
+}
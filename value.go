@@ -0,0 +1,210 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseBool converts value to a boolean. Accepted values are those of
+// strconv.ParseBool, e.g., "true", "false", "1" and "0".
+func parseBool(value string) (bool, error) {
+	return strconv.ParseBool(strings.TrimSpace(value))
+}
+
+// parseInt converts value to an integer. Prefixes "0x", "0o" and "0b"
+// select hexadecimal, octal and binary bases.
+func parseInt(value string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(value), 0, 64)
+}
+
+// parseFloat converts value to a floating point number.
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(value), 64)
+}
+
+// unwrapNumError returns the cause of strconv errors, which repeat the
+// value.
+func unwrapNumError(err error) error {
+	numErr, ok := err.(*strconv.NumError)
+	if ok {
+		return numErr.Err
+	}
+
+	return err
+}
+
+// convert retrieves the value of label l of section s and converts it with
+// function parse.
+func convert[T any](snap *Snapshot, s string, l string, parse func(string) (T, error)) (T, error) {
+	snap.track(s, l)
+	value, exists := snap.lookup(s, l)
+	if !exists {
+		var zero T
+		return zero, &ValueError{s, l, value, ErrNoValue}
+	}
+
+	result, err := parse(value)
+	if err != nil {
+		return result, &ValueError{s, l, value, unwrapNumError(err)}
+	}
+
+	return result, nil
+}
+
+// SetExpressions enables or disables evaluating the values of numeric
+// getters as expressions, from c or from its snapshots. Expressions support
+// arithmetic, parentheses, math functions such as sin, cos, sqrt and
+// deg2rad, and references to other labels of the same section, e.g.,
+// "1/20", "0.35 * 2" or "deg2rad(Heading) / 2". For Int and Float,
+// referenced values with units are converted to SI units. For Quantity,
+// expressions are evaluated in its default unit: referenced values are
+// converted to it and must have its dimension, otherwise an error wrapping
+// ErrUnit is returned. Errors report the position of the problem in the
+// value as an *ExprError.
+func (c *Config) SetExpressions(enable bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.exprs = enable
+	c.republish()
+}
+
+// evalUnit is the unit of the expressions evaluated by Snapshot.Quantity.
+type evalUnit struct {
+	def  unit   // Default unit.
+	name string // Name of the default unit.
+}
+
+// convert parses value, a number optionally followed by a unit, and returns
+// it in unit q, or in SI units if q is nil. It reports whether value has
+// that syntax, even if its unit has the wrong dimension.
+func (q *evalUnit) convert(value string) (float64, bool, error) {
+	if q == nil {
+		number, _, err := parseMeasure(value, dimensionless)
+		return number, err == nil, err
+	}
+
+	number, u, err := parseMeasure(value, q.def)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if u.dim != q.def.dim {
+		_, err = parseQuantity(value, q.def, q.name)
+		return 0, true, err
+	}
+
+	return number / q.def.scale, true, nil
+}
+
+// eval evaluates value of label l of section s as an expression in unit q,
+// or in SI units if q is nil. Set visiting holds the labels being
+// evaluated, to detect circular references.
+func (snap *Snapshot) eval(s string, l string, value string, q *evalUnit, visiting map[string]bool) (float64, error) {
+	if visiting == nil {
+		visiting = make(map[string]bool)
+	}
+
+	visiting[l] = true
+	defer delete(visiting, l)
+
+	return evalExpr(value, func(label string) (float64, error) {
+		if visiting[label] {
+			return 0, errors.New("circular reference")
+		}
+
+		snap.track(s, label)
+		value, exists := snap.lookup(s, label)
+		if !exists {
+			return 0, &ValueError{s, label, value, ErrNoValue}
+		}
+
+		number, parsed, err := q.convert(value)
+		if parsed || !snap.exprs {
+			if err != nil {
+				return 0, &ValueError{s, label, value, err}
+			}
+
+			return number, nil
+		}
+
+		return snap.eval(s, label, value, q, visiting)
+	})
+}
+
+// Bool retrieves the value of label l of section s as a boolean.
+func (snap *Snapshot) Bool(s string, l string) (bool, error) {
+	return convert(snap, s, l, parseBool)
+}
+
+// Int retrieves the value of label l of section s as an integer. See
+// Config.SetExpressions for the evaluation of expressions.
+func (snap *Snapshot) Int(s string, l string) (int64, error) {
+	return convert(snap, s, l, func(value string) (int64, error) {
+		n, err := parseInt(value)
+		if err == nil || !snap.exprs {
+			return n, err
+		}
+
+		result, err := snap.eval(s, l, value, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		if !isInteger(result) {
+			return 0, fmt.Errorf("not an integer: %g", result)
+		}
+
+		return int64(math.Round(result)), nil
+	})
+}
+
+// Float retrieves the value of label l of section s as a floating point
+// number. See Config.SetExpressions for the evaluation of expressions.
+func (snap *Snapshot) Float(s string, l string) (float64, error) {
+	return convert(snap, s, l, func(value string) (float64, error) {
+		number, err := parseFloat(value)
+		if err == nil || !snap.exprs {
+			return number, err
+		}
+
+		return snap.eval(s, l, value, nil, nil)
+	})
+}
+
+// Bool retrieves the value of label l of section s as a boolean.
+func (c *Config) Bool(s string, l string) (bool, error) {
+	return c.Snapshot().Bool(s, l)
+}
+
+// Int retrieves the value of label l of section s as an integer.
+func (c *Config) Int(s string, l string) (int64, error) {
+	return c.Snapshot().Int(s, l)
+}
+
+// Float retrieves the value of label l of section s as a floating point
+// number.
+func (c *Config) Float(s string, l string) (float64, error) {
+	return c.Snapshot().Float(s, l)
+}
@@ -0,0 +1,189 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestTypedGetters(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{
+		"S0": {"Bool": "true", "Int": " 0x1F ", "Float": "2.5e-1", "Text": "abc"},
+	})
+
+	b, err := c.Bool("S0", "Bool")
+	if err != nil || !b {
+		t.Errorf("expected: %v, actual: %v, %v", true, b, err)
+	}
+
+	i, err := c.Int("S0", "Int")
+	if err != nil || i != 31 {
+		t.Errorf("expected: %v, actual: %v, %v", 31, i, err)
+	}
+
+	f, err := c.Float("S0", "Float")
+	if err != nil || f != 0.25 {
+		t.Errorf("expected: %v, actual: %v, %v", 0.25, f, err)
+	}
+
+	_, err = c.Int("S0", "Text")
+	if !errors.Is(err, strconv.ErrSyntax) || err.Error() != `S0: Text: invalid value "abc": invalid syntax` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = c.Float("S0", "Missing")
+	if !errors.Is(err, ErrNoValue) || err.Error() != "S0: Missing: no value" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{
+		"S0": {
+			"Frequency": "20",
+			"Period":    "1/Frequency",
+			"Offset":    "0.35 * 2",
+			"Heading":   "90 deg",
+			"Half":      "Heading / 2",
+			"Retries":   "Frequency / 4",
+			"Timeout":   "3 * Period",
+			"Speed":     "2 * 1.5",
+			"A":         "B + 1",
+			"B":         "2 * A",
+			"Bad":       "2 * (Offset",
+			"Fraction":  "Offset / 2",
+		},
+		"S1": {
+			"Cycle":  "50 ms",
+			"Wait":   "3 * Cycle",
+			"Delay":  "Wait - 50",
+			"Speed":  "2 km/h",
+			"Double": "Speed * 2",
+			"Mass":   "5 kg",
+			"Fast":   "2 * Cycle",
+		},
+	})
+
+	// Disabled by default.
+	_, err := c.Float("S0", "Offset")
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected: %v, actual: %v", strconv.ErrSyntax, err)
+	}
+
+	c.SetExpressions(true)
+	snap := c.Snapshot()
+
+	floats := []struct {
+		label    string
+		expected float64
+	}{
+		{"Frequency", 20},
+		{"Period", 0.05},
+		{"Offset", 0.7},
+		{"Half", 0.7853981633974483},
+		{"Timeout", 0.15000000000000002},
+	}
+
+	for _, test := range floats {
+		actual, err := snap.Float("S0", test.label)
+		if err != nil || actual != test.expected {
+			t.Errorf("%s: expected: %v, actual: %v, %v", test.label, test.expected, actual, err)
+		}
+	}
+
+	n, err := c.Int("S0", "Retries")
+	if err != nil || n != 5 {
+		t.Errorf("expected: %v, actual: %v, %v", 5, n, err)
+	}
+
+	speed, err := c.Quantity("S0", "Speed", "km/h")
+	if err != nil || speed != 3/3.6 {
+		t.Errorf("expected: %v, actual: %v, %v", 3/3.6, speed, err)
+	}
+
+	quantities := []struct {
+		label    string
+		unit     string
+		expected float64
+	}{
+		{"Wait", "ms", 0.15},
+		{"Wait", "s", 0.15},
+		{"Delay", "ms", 0.1},
+		{"Double", "m/s", 2 * 2 / 3.6},
+		{"Double", "km/h", 2 * 2 / 3.6},
+	}
+
+	for _, test := range quantities {
+		actual, err := c.Quantity("S1", test.label, test.unit)
+		if err != nil || math.Abs(actual-test.expected) > 1e-12 {
+			t.Errorf("%s in %s: expected: %v, actual: %v, %v", test.label, test.unit, test.expected, actual, err)
+		}
+	}
+
+	unitErrs := []struct {
+		label    string
+		expected string
+	}{
+		{"Mass", `S1: Mass: invalid value "5 kg": incompatible unit "kg", expected "m/s"`},
+		{"Fast", `S1: Fast: invalid value "2 * Cycle": expression "2 * Cycle": column 5: ` +
+			`S1: Cycle: invalid value "50 ms": incompatible unit "ms", expected "m/s"`},
+	}
+
+	for _, test := range unitErrs {
+		_, err := c.Quantity("S1", test.label, "m/s")
+		if !errors.Is(err, ErrUnit) || err.Error() != test.expected {
+			t.Errorf("%s: expected: %q, actual: %v", test.label, test.expected, err)
+		}
+	}
+
+	errs := []struct {
+		label    string
+		column   int
+		expected string
+	}{
+		{"A", 1, `S0: A: invalid value "B + 1": expression "B + 1": column 1: ` +
+			`expression "2 * A": column 5: circular reference`},
+		{"Bad", 5, `S0: Bad: invalid value "2 * (Offset": expression "2 * (Offset": column 5: unbalanced parenthesis`},
+	}
+
+	for _, test := range errs {
+		_, err := c.Float("S0", test.label)
+
+		var exprErr *ExprError
+		if !errors.As(err, &exprErr) || exprErr.Column() != test.column || err.Error() != test.expected {
+			t.Errorf("expected: %q, actual: %v", test.expected, err)
+		}
+	}
+
+	_, err = c.Int("S0", "Fraction")
+	if err == nil || err.Error() != `S0: Fraction: invalid value "Offset / 2": not an integer: 0.35` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	c.SetExpressions(false)
+	_, err = c.Float("S0", "Offset")
+	if err == nil {
+		t.Errorf("expected error")
+	}
+}
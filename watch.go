@@ -0,0 +1,284 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"strings"
+	"sync"
+)
+
+// ChangeKind identifies the kind of a Change.
+type ChangeKind int
+
+const (
+	// ValueAdded is the assignment of a label that did not exist.
+	ValueAdded ChangeKind = iota + 1
+	// ValueModified is the assignment of a different value to a label.
+	ValueModified
+	// ValueRemoved is the removal of a label.
+	ValueRemoved
+)
+
+// Change describes the modification of a value of a Config.
+type Change struct {
+	Kind    ChangeKind // Kind of change.
+	Section string     // Section name.
+	Label   string     // Label.
+	Old     string     // Old value, empty if added.
+	New     string     // New value, empty if removed.
+	Source  string     // Source of the modification, e.g., "file.ini:12".
+}
+
+// Filter selects the changes delivered to a subscriber.
+type Filter struct {
+	Section string // Prefix of section names, empty for all sections.
+	Label   string // Prefix of labels, empty for all labels.
+}
+
+// match tests if change matches the filter.
+func (f Filter) match(change *Change) bool {
+	return strings.HasPrefix(change.Section, f.Section) &&
+		strings.HasPrefix(change.Label, f.Label)
+}
+
+// maxQueuedBatches is the number of batches of changes queued for a
+// subscriber before further batches are coalesced.
+const maxQueuedBatches = 64
+
+// Subscription is the registration of a subscriber with a Config.
+// Changes are delivered in commit order from a goroutine dedicated to the
+// subscription, so slow subscribers never block writers. If a subscriber
+// falls behind by more than maxQueuedBatches batches, the batches that
+// follow are coalesced into one, reporting the net change of each value, so
+// that memory use stays bounded.
+type Subscription struct {
+	c       *Config        // Configuration.
+	filter  Filter         // Selected changes.
+	fn      func([]Change) // Subscriber.
+	onClose func()         // Called after the last delivery.
+	lock    sync.Mutex     // Guards queue and stopped.
+	cond    *sync.Cond     // Signals queue or stopped.
+	queue   [][]Change     // Batches not yet delivered.
+	stopped bool           // Subscription was cancelled.
+	done    chan struct{}  // Closed when the subscription is cancelled.
+}
+
+// Subscribe calls fn with the changes of each committed modification of c
+// that match filter f. A transaction committed by Update results in a
+// single call. Calls are made in commit order, one at a time, from a
+// goroutine other than the writer's, so fn may read and modify c.
+func (c *Config) Subscribe(f Filter, fn func(changes []Change)) *Subscription {
+	sub := c.newSubscription(f, fn, nil)
+	c.addSubscription(sub)
+	return sub
+}
+
+// Watch returns a channel on which the changes of c that match filter f are
+// delivered, one at a time, in commit order. The channel is closed after
+// the subscription is cancelled.
+func (c *Config) Watch(f Filter) (<-chan Change, *Subscription) {
+	ch := make(chan Change)
+
+	sub := c.newSubscription(f, nil, func() {
+		close(ch)
+	})
+
+	sub.fn = func(changes []Change) {
+		for _, change := range changes {
+			select {
+			case ch <- change:
+			case <-sub.done:
+				return
+			}
+		}
+	}
+
+	c.addSubscription(sub)
+	return ch, sub
+}
+
+func (c *Config) newSubscription(f Filter, fn func([]Change), onClose func()) *Subscription {
+	sub := &Subscription{
+		c:       c,
+		filter:  f,
+		fn:      fn,
+		onClose: onClose,
+		done:    make(chan struct{}),
+	}
+
+	sub.cond = sync.NewCond(&sub.lock)
+	return sub
+}
+
+func (c *Config) addSubscription(sub *Subscription) {
+	c.subsLock.Lock()
+	if c.subs == nil {
+		c.subs = make(map[*Subscription]bool)
+	}
+	c.subs[sub] = true
+	c.subsLock.Unlock()
+
+	go sub.run()
+}
+
+// hasSubscriptions tests if any subscriber is registered.
+func (c *Config) hasSubscriptions() bool {
+	c.subsLock.Lock()
+	defer c.subsLock.Unlock()
+	return len(c.subs) > 0
+}
+
+// notify queues changes for delivery to the subscribers.
+func (c *Config) notify(changes []Change) {
+	c.subsLock.Lock()
+	defer c.subsLock.Unlock()
+
+	for sub := range c.subs {
+		sub.push(changes)
+	}
+}
+
+// Unsubscribe cancels the subscription. Changes not yet delivered are
+// dropped. Unsubscribe may be called more than once, also by the
+// subscriber.
+func (sub *Subscription) Unsubscribe() {
+	sub.c.subsLock.Lock()
+	delete(sub.c.subs, sub)
+	sub.c.subsLock.Unlock()
+
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	if !sub.stopped {
+		sub.stopped = true
+		sub.queue = nil
+		close(sub.done)
+		sub.cond.Signal()
+	}
+}
+
+// push queues the changes that match the filter.
+func (sub *Subscription) push(changes []Change) {
+	var batch []Change
+	for i := range changes {
+		if sub.filter.match(&changes[i]) {
+			batch = append(batch, changes[i])
+		}
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	sub.lock.Lock()
+	defer sub.lock.Unlock()
+
+	if sub.stopped {
+		return
+	}
+
+	if len(sub.queue) >= maxQueuedBatches {
+		last := len(sub.queue) - 1
+		sub.queue[last] = coalesce(sub.queue[last], batch)
+		if len(sub.queue[last]) == 0 {
+			sub.queue = sub.queue[:last]
+		}
+	} else {
+		sub.queue = append(sub.queue, batch)
+	}
+
+	sub.cond.Signal()
+}
+
+// coalesce returns the net changes of batch a followed by batch b, in order
+// of first modification. Values modified back to their original state are
+// omitted.
+func coalesce(a []Change, b []Change) []Change {
+	type netChange struct {
+		first Change // First change of the value.
+		last  Change // Last change of the value.
+	}
+
+	var nets []netChange
+	index := make(map[valueKey]int)
+	for _, changes := range [][]Change{a, b} {
+		for _, change := range changes {
+			key := valueKey{change.Section, change.Label}
+			i, exists := index[key]
+			if !exists {
+				index[key] = len(nets)
+				nets = append(nets, netChange{change, change})
+			} else {
+				nets[i].last = change
+			}
+		}
+	}
+
+	var result []Change
+	for _, net := range nets {
+		oldExists := net.first.Kind != ValueAdded
+		newExists := net.last.Kind != ValueRemoved
+
+		change := net.last
+		change.Old = net.first.Old
+
+		switch {
+		case !oldExists && !newExists:
+			continue
+		case !oldExists:
+			change.Kind = ValueAdded
+		case !newExists:
+			change.Kind = ValueRemoved
+		case change.Old != change.New:
+			change.Kind = ValueModified
+		default:
+			continue
+		}
+
+		result = append(result, change)
+	}
+
+	return result
+}
+
+// run delivers queued changes until the subscription is cancelled.
+func (sub *Subscription) run() {
+	if sub.onClose != nil {
+		defer sub.onClose()
+	}
+
+	for {
+		sub.lock.Lock()
+		for len(sub.queue) == 0 && !sub.stopped {
+			sub.cond.Wait()
+		}
+
+		if sub.stopped {
+			sub.lock.Unlock()
+			return
+		}
+
+		batch := sub.queue[0]
+		sub.queue[0] = nil
+		sub.queue = sub.queue[1:]
+		sub.lock.Unlock()
+
+		sub.fn(batch)
+	}
+}
@@ -0,0 +1,270 @@
+//***************************************************************************
+// Copyright 2018 OceanScan - Marine Systems & Technology, Lda.             *
+//***************************************************************************
+// Licensed under the Apache License, Version 2.0 (the "License");          *
+// you may not use this file except in compliance with the License.         *
+// You may obtain a copy of the License at                                  *
+//                                                                          *
+// http://www.apache.org/licenses/LICENSE-2.0                               *
+//                                                                          *
+// Unless required by applicable law or agreed to in writing, software      *
+// distributed under the License is distributed on an "AS IS" BASIS,        *
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. *
+// See the License for the specific language governing permissions and      *
+// limitations under the License.                                           *
+//***************************************************************************
+// Author: Ricardo Martins                                                  *
+//***************************************************************************
+
+package ini
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receiveWithin waits for a value on ch, or fails after a timeout.
+func receiveWithin[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+
+	select {
+	case value := <-ch:
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %T", *new(T))
+		return *new(T)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c := NewConfig()
+	c.SetMap(map[string]map[string]string{
+		"Gains": {"Kp": "1", "Ki": "0.1", "Kd": "0.01"},
+	})
+
+	ch := make(chan []Change, 10)
+	sub := c.Subscribe(Filter{}, func(changes []Change) {
+		ch <- changes
+	})
+	defer sub.Unsubscribe()
+
+	c.Update(func(tx *Tx) error {
+		tx.SetSource("tuner")
+		tx.SetValue("Gains", "Kp", "2")
+		tx.SetValue("Gains", "Ki", "0.1")
+		tx.Remove("Gains", "Kd")
+		tx.SetValue("Gains", "Kf", "3")
+		return nil
+	})
+
+	expected := []Change{
+		{ValueModified, "Gains", "Kp", "1", "2", "tuner"},
+		{ValueRemoved, "Gains", "Kd", "0.01", "", "tuner"},
+		{ValueAdded, "Gains", "Kf", "", "3", "tuner"},
+	}
+
+	actual := receiveWithin(t, ch)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+
+	// No changes, no notification.
+	c.SetValue("Gains", "Kp", "2")
+	c.SetValue("Gains", "Kp", "4")
+
+	expected = []Change{{ValueModified, "Gains", "Kp", "2", "4", ""}}
+	actual = receiveWithin(t, ch)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	changes := []Change{
+		{Section: "Control", Label: "Gain"},
+		{Section: "Control", Label: "Period"},
+		{Section: "Navigation", Label: "Gain"},
+	}
+
+	tests := []struct {
+		filter   Filter
+		expected []string
+	}{
+		{Filter{}, []string{"Control.Gain", "Control.Period", "Navigation.Gain"}},
+		{Filter{Section: "Control"}, []string{"Control.Gain", "Control.Period"}},
+		{Filter{Label: "Gain"}, []string{"Control.Gain", "Navigation.Gain"}},
+		{Filter{Section: "Nav", Label: "G"}, []string{"Navigation.Gain"}},
+		{Filter{Section: "Other"}, nil},
+	}
+
+	for _, test := range tests {
+		var actual []string
+		for i := range changes {
+			if test.filter.match(&changes[i]) {
+				actual = append(actual, changes[i].Section+"."+changes[i].Label)
+			}
+		}
+
+		if !reflect.DeepEqual(test.expected, actual) {
+			t.Errorf("expected: %v, actual: %v", test.expected, actual)
+		}
+	}
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	c := NewConfig()
+
+	ch := make(chan []Change, 10)
+	sub := c.Subscribe(Filter{Section: "Control"}, func(changes []Change) {
+		ch <- changes
+	})
+	defer sub.Unsubscribe()
+
+	c.SetValue("Navigation", "Gain", "1")
+	c.Update(func(tx *Tx) error {
+		tx.SetValue("Control", "Gain", "1")
+		tx.SetValue("Navigation", "Gain", "2")
+		return nil
+	})
+
+	expected := []Change{{ValueAdded, "Control", "Gain", "", "1", ""}}
+	actual := receiveWithin(t, ch)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c := NewConfig()
+	ch, sub := c.Watch(Filter{Section: "S0"})
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			c.SetValue("S0", "L0", strings.Repeat("x", i+1))
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		change := <-ch
+		if change.New != strings.Repeat("x", i+1) {
+			t.Errorf("expected: %q, actual: %q", strings.Repeat("x", i+1), change.New)
+		}
+	}
+
+	// Cancelling a watch with pending changes closes the channel.
+	c.SetValue("S0", "L1", "V0")
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+
+	for range ch {
+	}
+
+	c.SetValue("S0", "L1", "V1")
+}
+
+func TestSubscribeParse(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("General", "Speed", "1")
+
+	ch := make(chan []Change, 10)
+	sub := c.Subscribe(Filter{}, func(changes []Change) {
+		ch <- changes
+	})
+	defer sub.Unsubscribe()
+
+	p := NewParser(c)
+	err := p.Parse(strings.NewReader("[General]\nSpeed = 2\nDepth = 3\nDepth += 4\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Change{
+		{ValueModified, "General", "Speed", "1", "2", "2"},
+		{ValueAdded, "General", "Depth", "", "3 4", "4"},
+	}
+
+	actual := receiveWithin(t, ch)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	a := []Change{
+		{ValueModified, "S0", "L0", "1", "2", "a"},
+		{ValueAdded, "S0", "L1", "", "1", "a"},
+		{ValueRemoved, "S0", "L2", "1", "", "a"},
+	}
+
+	b := []Change{
+		{ValueAdded, "S0", "L3", "", "1", "b"},
+		{ValueModified, "S0", "L0", "2", "3", "b"},
+		{ValueRemoved, "S0", "L1", "1", "", "b"},
+		{ValueAdded, "S0", "L2", "", "1", "b"},
+	}
+
+	expected := []Change{
+		{ValueModified, "S0", "L0", "1", "3", "b"},
+		{ValueAdded, "S0", "L3", "", "1", "b"},
+	}
+
+	actual := coalesce(a, b)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestSubscribeSlow(t *testing.T) {
+	c := NewConfig()
+	c.SetValue("S0", "L0", "0")
+
+	release := make(chan struct{})
+	ch := make(chan []Change, 2*maxQueuedBatches)
+	sub := c.Subscribe(Filter{}, func(changes []Change) {
+		<-release
+		ch <- changes
+	})
+	defer sub.Unsubscribe()
+
+	const nrUpdates = 10 * maxQueuedBatches
+	for i := 1; i <= nrUpdates; i++ {
+		c.SetValue("S0", "L0", fmt.Sprint(i))
+		c.SetValue("S1", fmt.Sprintf("L%d", i%4), fmt.Sprint(i))
+	}
+	c.SetValue("S2", "Done", "1")
+
+	sub.lock.Lock()
+	queued := len(sub.queue)
+	sub.lock.Unlock()
+
+	if queued > maxQueuedBatches {
+		t.Errorf("expected at most %d queued batches, actual: %d", maxQueuedBatches, queued)
+	}
+
+	close(release)
+
+	// The changes delivered add up to the final state.
+	values := map[string]string{"S0.L0": "0"}
+	for values["S2.Done"] == "" {
+		for _, change := range receiveWithin(t, ch) {
+			key := change.Section + "." + change.Label
+			if values[key] != change.Old {
+				t.Fatalf("%s: expected old value: %q, actual: %q", key, values[key], change.Old)
+			}
+
+			values[key] = change.New
+		}
+	}
+
+	expected := map[string]string{"S0.L0": fmt.Sprint(nrUpdates), "S2.Done": "1"}
+	for i := nrUpdates - 3; i <= nrUpdates; i++ {
+		expected[fmt.Sprintf("S1.L%d", i%4)] = fmt.Sprint(i)
+	}
+
+	if !reflect.DeepEqual(expected, values) {
+		t.Errorf("expected: %v, actual: %v", expected, values)
+	}
+}